@@ -1,7 +1,21 @@
 package checker
 
-import "github.com/rezikovka/gonkey/models"
+import (
+	"context"
+
+	"github.com/rezikovka/gonkey/models"
+)
 
 type CheckerInterface interface {
 	Check(models.TestInterface, *models.Result) ([]error, error)
 }
+
+// NamedCheckerInterface is implemented by checkers which can be referred to from tests by name
+type NamedCheckerInterface interface {
+	Name() string
+}
+
+// ContextCheckerInterface is implemented by checkers which can be cancelled
+type ContextCheckerInterface interface {
+	CheckContext(context.Context, models.TestInterface, *models.Result) ([]error, error)
+}
@@ -0,0 +1,216 @@
+package response_body
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/rezikovka/gonkey/compare"
+	"github.com/rezikovka/gonkey/models"
+)
+
+var assertionTypes = map[string]bool{
+	"string":  true,
+	"number":  true,
+	"boolean": true,
+	"null":    true,
+	"array":   true,
+	"object":  true,
+}
+
+// checkAssertions checks values of the JSON body by the assertions,
+// the error is returned if an assertion is invalid
+func checkAssertions(assertions []*models.ResponseAssertion, body string) ([]error, error) {
+	for i, a := range assertions {
+		if err := validateAssertion(a); err != nil {
+			return nil, fmt.Errorf("invalid response assertion #%d: %s", i, err)
+		}
+	}
+
+	if !gjson.Valid(body) {
+		return []error{errors.New("could not parse response")}, nil
+	}
+
+	var errs []error
+	for _, a := range assertions {
+		errs = append(errs, checkAssertion(a, body)...)
+	}
+	return errs, nil
+}
+
+func validateAssertion(a *models.ResponseAssertion) error {
+	if a == nil || a.Path == "" {
+		return errors.New("`path` is required")
+	}
+	if a.Exists == nil && !hasValueOperators(a) {
+		return fmt.Errorf("no operators for path %s", a.Path)
+	}
+	if a.Regex != "" {
+		if _, err := regexp.Compile(a.Regex); err != nil {
+			return fmt.Errorf("invalid regex for path %s: %s", a.Path, err)
+		}
+	}
+	if a.Type != "" && !assertionTypes[a.Type] {
+		return fmt.Errorf("unknown type %s for path %s", a.Type, a.Path)
+	}
+	return nil
+}
+
+func checkAssertion(a *models.ResponseAssertion, body string) []error {
+	path := "$." + a.Path
+	value := gjson.Get(body, a.Path)
+
+	if a.Exists != nil && *a.Exists != value.Exists() {
+		return []error{compare.MakeError(path, "value presence does not match", presence(*a.Exists), presence(value.Exists()))}
+	}
+	if !value.Exists() {
+		if !hasValueOperators(a) {
+			return nil
+		}
+		return []error{compare.MakeError(path, "value is missing", "<value>", "<missing>")}
+	}
+
+	var errs []error
+
+	if a.Type != "" && a.Type != jsonType(value) {
+		errs = append(errs, compare.MakeError(path, "types do not match", a.Type, jsonType(value)))
+	}
+
+	if a.Equals != nil || a.EqualsSet {
+		errs = append(errs, compare.CompareAt(path, normalize(a.Equals), value.Value(), compare.CompareParams{})...)
+	}
+
+	if a.Regex != "" {
+		if value.Type != gjson.String {
+			errs = append(errs, compare.MakeError(path, "type mismatch", "string", jsonType(value)))
+		} else if !regexp.MustCompile(a.Regex).MatchString(value.Str) {
+			errs = append(errs, compare.MakeError(path, "value does not match regex", a.Regex, value.Str))
+		}
+	}
+
+	if a.Length != nil {
+		if length, ok := lengthOf(value); !ok {
+			errs = append(errs, compare.MakeError(path, "value has no length", "array, string or object", jsonType(value)))
+		} else if length != *a.Length {
+			errs = append(errs, compare.MakeError(path, "lengths do not match", *a.Length, length))
+		}
+	}
+
+	if a.GreaterThan != nil || a.LessThan != nil {
+		if value.Type != gjson.Number {
+			errs = append(errs, compare.MakeError(path, "type mismatch", "number", jsonType(value)))
+		} else {
+			if a.GreaterThan != nil && !(value.Num > *a.GreaterThan) {
+				errs = append(errs, compare.MakeError(path, "value is not greater", fmt.Sprintf("> %v", *a.GreaterThan), value.Num))
+			}
+			if a.LessThan != nil && !(value.Num < *a.LessThan) {
+				errs = append(errs, compare.MakeError(path, "value is not less", fmt.Sprintf("< %v", *a.LessThan), value.Num))
+			}
+		}
+	}
+
+	if a.Contains != nil {
+		if err := checkContains(path, value, normalize(a.Contains)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// hasValueOperators returns true if the assertion checks the value besides its presence
+func hasValueOperators(a *models.ResponseAssertion) bool {
+	return a.Equals != nil || a.EqualsSet || a.Regex != "" || a.Length != nil || a.GreaterThan != nil ||
+		a.LessThan != nil || a.Contains != nil || a.Type != ""
+}
+
+func checkContains(path string, value gjson.Result, expected interface{}) error {
+	switch {
+	case value.IsArray():
+		for _, item := range value.Array() {
+			if len(compare.Compare(expected, item.Value(), compare.CompareParams{})) == 0 {
+				return nil
+			}
+		}
+		return compare.MakeError(path, "array does not contain the element", expected, value.Raw)
+	case value.Type == gjson.String:
+		substr, ok := expected.(string)
+		if !ok {
+			return compare.MakeError(path, "type mismatch", "string", fmt.Sprintf("%T", expected))
+		}
+		if !strings.Contains(value.Str, substr) {
+			return compare.MakeError(path, "string does not contain the substring", substr, value.Str)
+		}
+		return nil
+	default:
+		return compare.MakeError(path, "value can not contain elements", "array or string", jsonType(value))
+	}
+}
+
+func lengthOf(value gjson.Result) (int, bool) {
+	switch {
+	case value.IsArray():
+		return len(value.Array()), true
+	case value.IsObject():
+		return len(value.Map()), true
+	case value.Type == gjson.String:
+		return len([]rune(value.Str)), true
+	default:
+		return 0, false
+	}
+}
+
+func jsonType(value gjson.Result) string {
+	switch {
+	case value.IsArray():
+		return "array"
+	case value.IsObject():
+		return "object"
+	}
+	switch value.Type {
+	case gjson.String:
+		return "string"
+	case gjson.Number:
+		return "number"
+	case gjson.True, gjson.False:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+func presence(exists bool) string {
+	if exists {
+		return "<exists>"
+	}
+	return "<missing>"
+}
+
+// normalize converts the value decoded from YAML to the types which are produced by JSON decoding
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprintf("%v", key)] = normalize(item)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, item := range v {
+			s[i] = normalize(item)
+		}
+		return s
+	default:
+		return value
+	}
+}
@@ -0,0 +1,282 @@
+package response_body
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+
+	"github.com/rezikovka/gonkey/models"
+)
+
+const assertionsBody = `{
+	"data": {
+		"orders": [
+			{"id": 41, "status": "paid", "comment": null},
+			{"id": 42, "status": "new", "tags": ["urgent", "gift"]}
+		],
+		"total": 2,
+		"currency": "EUR",
+		"owner": {"name": "Bob", "email": "bob@example.com"}
+	}
+}`
+
+func decodeAssertions(t *testing.T, s string) []*models.ResponseAssertion {
+	var assertions []*models.ResponseAssertion
+	require.NoError(t, yaml.Unmarshal([]byte(s), &assertions))
+	return assertions
+}
+
+func TestCheckAssertions(t *testing.T) {
+	tests := []struct {
+		name           string
+		assertions     string
+		expectedErrors []string
+	}{
+		{
+			name: "equals",
+			assertions: `
+- path: data.orders.0.status
+  equals: paid
+- path: data.total
+  equals: 2
+- path: data.owner
+  equals: {name: Bob, email: bob@example.com}
+- path: data.orders.1.tags
+  equals: [urgent, gift]`,
+		},
+		{
+			name: "equals with matcher",
+			assertions: `
+- path: data.owner.email
+  equals: $matchRegexp(^[a-z]+@example\.com$)
+- path: data.owner
+  equals: {name: $matchType(string)}`,
+		},
+		{
+			name: "equals fails",
+			assertions: `
+- path: data.orders.0.status
+  equals: new`,
+			expectedErrors: []string{"values do not match"},
+		},
+		{
+			name: "equals null",
+			assertions: `
+- path: data.orders.0.comment
+  equals: null`,
+		},
+		{
+			name: "equals null fails",
+			assertions: `
+- path: data.orders.0.status
+  equals: null`,
+			expectedErrors: []string{"types do not match"},
+		},
+		{
+			name: "equals null of missing value",
+			assertions: `
+- path: data.orders.1.comment
+  equals: ~`,
+			expectedErrors: []string{"value is missing"},
+		},
+		{
+			name: "regex",
+			assertions: `
+- path: data.currency
+  regex: ^[A-Z]{3}$`,
+		},
+		{
+			name: "regex fails",
+			assertions: `
+- path: data.currency
+  regex: ^[a-z]+$
+- path: data.total
+  regex: ^[0-9]+$`,
+			expectedErrors: []string{"value does not match regex", "type mismatch"},
+		},
+		{
+			name: "exists",
+			assertions: `
+- path: data.orders.0.comment
+  exists: true
+- path: data.orders.0.tags
+  exists: false`,
+		},
+		{
+			name: "exists fails",
+			assertions: `
+- path: data.orders.1.comment
+  exists: true
+- path: data.orders.1.tags
+  exists: false`,
+			expectedErrors: []string{"value presence does not match", "value presence does not match"},
+		},
+		{
+			name: "length",
+			assertions: `
+- path: data.orders
+  length: 2
+- path: data.currency
+  length: 3
+- path: data.owner
+  length: 2`,
+		},
+		{
+			name: "length fails",
+			assertions: `
+- path: data.orders
+  length: 3
+- path: data.total
+  length: 1`,
+			expectedErrors: []string{"lengths do not match", "value has no length"},
+		},
+		{
+			name: "greaterThan and lessThan",
+			assertions: `
+- path: data.total
+  greaterThan: 1
+  lessThan: 2.5`,
+		},
+		{
+			name: "greaterThan and lessThan fail",
+			assertions: `
+- path: data.total
+  greaterThan: 2
+  lessThan: 2
+- path: data.currency
+  greaterThan: 0`,
+			expectedErrors: []string{"value is not greater", "value is not less", "type mismatch"},
+		},
+		{
+			name: "contains",
+			assertions: `
+- path: data.orders.#.id
+  contains: 42
+- path: data.orders.1.tags
+  contains: gift
+- path: data.owner.email
+  contains: "@example"
+- path: data.orders
+  contains: {id: 41}`,
+		},
+		{
+			name: "contains fails",
+			assertions: `
+- path: data.orders.#.id
+  contains: 43
+- path: data.owner.email
+  contains: "@test"
+- path: data.owner.email
+  contains: 1
+- path: data.total
+  contains: 2`,
+			expectedErrors: []string{
+				"array does not contain the element",
+				"string does not contain the substring",
+				"type mismatch",
+				"value can not contain elements",
+			},
+		},
+		{
+			name: "type",
+			assertions: `
+- path: data.orders
+  type: array
+- path: data.owner
+  type: object
+- path: data.total
+  type: number
+- path: data.currency
+  type: string
+- path: data.orders.0.comment
+  type: "null"`,
+		},
+		{
+			name: "type fails",
+			assertions: `
+- path: data.total
+  type: string`,
+			expectedErrors: []string{"types do not match"},
+		},
+		{
+			name: "value of missing path",
+			assertions: `
+- path: data.missing
+  type: string`,
+			expectedErrors: []string{"value is missing"},
+		},
+		{
+			name: "all failed operators are reported",
+			assertions: `
+- path: data.currency
+  type: number
+  length: 2
+  equals: USD`,
+			expectedErrors: []string{"types do not match", "values do not match", "lengths do not match"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs, err := checkAssertions(decodeAssertions(t, tt.assertions), assertionsBody)
+			require.NoError(t, err)
+			require.Len(t, errs, len(tt.expectedErrors), "errors: %v", errs)
+			for i, err := range errs {
+				assert.Contains(t, err.Error(), tt.expectedErrors[i])
+			}
+		})
+	}
+}
+
+func TestInvalidAssertions(t *testing.T) {
+	tests := []struct {
+		name          string
+		assertions    string
+		expectedError string
+	}{
+		{
+			name:          "missing path",
+			assertions:    "- equals: 1",
+			expectedError: "invalid response assertion #0: `path` is required",
+		},
+		{
+			name:          "no operators",
+			assertions:    "- path: data.total",
+			expectedError: "invalid response assertion #0: no operators for path data.total",
+		},
+		{
+			name:          "invalid regex",
+			assertions:    "- path: data.total\n  exists: true\n- path: data.currency\n  regex: '['",
+			expectedError: "invalid response assertion #1: invalid regex for path data.currency: error parsing regexp: missing closing ]: `[`",
+		},
+		{
+			name:          "unknown type",
+			assertions:    "- path: data.total\n  type: integer",
+			expectedError: "invalid response assertion #0: unknown type integer for path data.total",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := checkAssertions(decodeAssertions(t, tt.assertions), assertionsBody)
+			assert.EqualError(t, err, tt.expectedError)
+		})
+	}
+}
+
+func TestAssertionsOfInvalidBody(t *testing.T) {
+	errs, err := checkAssertions(decodeAssertions(t, "- path: data\n  exists: true"), "not json")
+	require.NoError(t, err)
+	require.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "could not parse response")
+}
+
+func TestDecodeEqualsNull(t *testing.T) {
+	assertions := decodeAssertions(t, "- path: a\n  equals: null\n- path: b\n  equals: 0\n- path: c\n  exists: true")
+	assert.True(t, assertions[0].EqualsSet)
+	assert.Nil(t, assertions[0].Equals)
+	assert.True(t, assertions[1].EqualsSet)
+	assert.False(t, assertions[2].EqualsSet)
+}
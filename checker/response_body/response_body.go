@@ -9,6 +9,7 @@ import (
 	"github.com/rezikovka/gonkey/checker"
 	"github.com/rezikovka/gonkey/compare"
 	"github.com/rezikovka/gonkey/models"
+	"github.com/rezikovka/gonkey/xmldoc"
 )
 
 type ResponseBodyChecker struct {
@@ -19,23 +20,43 @@ func NewChecker() checker.CheckerInterface {
 	return &ResponseBodyChecker{}
 }
 
+func (c *ResponseBodyChecker) Name() string {
+	return "body"
+}
+
 func (c *ResponseBodyChecker) Check(t models.TestInterface, result *models.Result) ([]error, error) {
 	var errs []error
 	var foundResponse bool
 	// test response with the expected response body
 	if expectedBody, ok := t.GetResponse(result.ResponseStatusCode); ok {
 		foundResponse = true
+		captures := make(map[string]string)
 		// is the response JSON document?
 		if strings.Contains(result.ResponseContentType, "json") && expectedBody != "" {
-			checkErrs, err := compareJsonBody(t, expectedBody, result)
+			checkErrs, err := compareJsonBody(t, expectedBody, result, captures)
 			if err != nil {
 				return nil, err
 			}
 			errs = append(errs, checkErrs...)
+		} else if expected, ok := parseXmlBody(result.ResponseContentType, expectedBody); ok {
+			errs = append(errs, compareXmlBody(t, expected, result, captures)...)
 		} else {
 			// compare bodies as leaf nodes
-			errs = append(errs, compare.Compare(expectedBody, result.ResponseBody, compare.CompareParams{})...)
+			params := compare.CompareParams{Captures: captures}
+			errs = append(errs, bodyErrors(expectedBody, result.ResponseBody, params, compare.Compare(expectedBody, result.ResponseBody, params))...)
+		}
+		if len(captures) > 0 {
+			result.CapturedVariables = captures
+		}
+	}
+	// test values of the response by the assertions
+	if assertions, ok := t.GetResponseAssertions(result.ResponseStatusCode); ok {
+		foundResponse = true
+		checkErrs, err := checkAssertions(assertions, result.ResponseBody)
+		if err != nil {
+			return nil, fmt.Errorf("test %s (status %d): %s", t.GetName(), result.ResponseStatusCode, err)
 		}
+		errs = append(errs, checkErrs...)
 	}
 	if !foundResponse {
 		err := fmt.Errorf("server responded with status %d", result.ResponseStatusCode)
@@ -44,7 +65,7 @@ func (c *ResponseBodyChecker) Check(t models.TestInterface, result *models.Resul
 	return errs, nil
 }
 
-func compareJsonBody(t models.TestInterface, expectedBody string, result *models.Result) ([]error, error) {
+func compareJsonBody(t models.TestInterface, expectedBody string, result *models.Result, captures map[string]string) ([]error, error) {
 	// decode expected body
 	var expected interface{}
 	if err := json.Unmarshal([]byte(expectedBody), &expected); err != nil {
@@ -66,7 +87,47 @@ func compareJsonBody(t models.TestInterface, expectedBody string, result *models
 		IgnoreValues:         !t.NeedsCheckingValues(),
 		IgnoreArraysOrdering: t.IgnoreArraysOrdering(),
 		DisallowExtraFields:  t.DisallowExtraFields(),
+		Captures:             captures,
+	}
+
+	return bodyErrors(expected, actual, params, compare.Compare(expected, actual, params)), nil
+}
+
+// parseXmlBody decodes the expected body of the XML response, the body which isn't XML document,
+// e.g. the matcher expression like $matchRegexp(...) or the text fragment, is compared as leaf node
+func parseXmlBody(contentType, expectedBody string) (*xmldoc.Node, bool) {
+	if !strings.Contains(contentType, "xml") || expectedBody == "" {
+		return nil, false
+	}
+	expected, err := xmldoc.Parse(expectedBody)
+	if err != nil {
+		return nil, false
+	}
+	return expected, true
+}
+
+func compareXmlBody(t models.TestInterface, expected *xmldoc.Node, result *models.Result, captures map[string]string) []error {
+	// decode actual body
+	actual, err := xmldoc.Parse(result.ResponseBody)
+	if err != nil {
+		return []error{errors.New("could not parse response")}
+	}
+
+	params := compare.CompareParams{
+		IgnoreValues:         !t.NeedsCheckingValues(),
+		IgnoreArraysOrdering: t.IgnoreArraysOrdering(),
+		DisallowExtraFields:  t.DisallowExtraFields(),
+		Captures:             captures,
 	}
 
-	return compare.Compare(expected, actual, params), nil
+	expectedValue, actualValue := expected.Value(), actual.Value()
+	return bodyErrors(expectedValue, actualValue, params, compare.Compare(expectedValue, actualValue, params))
+}
+
+// bodyErrors reports mismatches of the body as the single error with the diff
+func bodyErrors(expected, actual interface{}, params compare.CompareParams, errs []error) []error {
+	if err := compare.NewDiffError("response body does not match", expected, actual, params, errs); err != nil {
+		return []error{err}
+	}
+	return nil
 }
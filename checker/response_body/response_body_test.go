@@ -0,0 +1,74 @@
+package response_body
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rezikovka/gonkey/models"
+	"github.com/rezikovka/gonkey/testloader/yaml_file"
+)
+
+func TestCheckXmlBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		actual   string
+		failed   bool
+	}{
+		{
+			name:     "document is compared structurally",
+			expected: `<order><id>42</id></order>`,
+			actual:   `<?xml version="1.0"?><order>  <id>42</id></order>`,
+		},
+		{
+			name:     "document mismatch",
+			expected: `<order><id>42</id></order>`,
+			actual:   `<order><id>43</id></order>`,
+			failed:   true,
+		},
+		{
+			name:     "regexp matcher",
+			expected: `$matchRegexp(<id>\d+</id>)`,
+			actual:   `<id>42</id>`,
+		},
+		{
+			name:     "regexp matcher mismatch",
+			expected: `$matchRegexp(<id>\d+</id>)`,
+			actual:   `<id>abc</id>`,
+			failed:   true,
+		},
+		{
+			name:     "any matcher",
+			expected: `$any`,
+			actual:   `<order/>`,
+		},
+		{
+			name:     "text fragment",
+			expected: `not a document`,
+			actual:   `not a document`,
+		},
+		{
+			name:     "text fragment mismatch",
+			expected: `not a document`,
+			actual:   `<order/>`,
+			failed:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test := &yaml_file.Test{Responses: map[int]string{200: tt.expected}}
+			result := &models.Result{
+				ResponseStatusCode:  200,
+				ResponseContentType: "text/xml",
+				ResponseBody:        tt.actual,
+			}
+
+			errs, err := NewChecker().Check(test, result)
+			require.NoError(t, err)
+			assert.Equal(t, tt.failed, len(errs) > 0, "%v", errs)
+		})
+	}
+}
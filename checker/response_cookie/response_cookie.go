@@ -0,0 +1,124 @@
+package response_cookie
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rezikovka/gonkey/checker"
+	"github.com/rezikovka/gonkey/compare"
+	"github.com/rezikovka/gonkey/models"
+)
+
+type ResponseCookieChecker struct {
+	checker.CheckerInterface
+}
+
+func NewChecker() checker.CheckerInterface {
+	return &ResponseCookieChecker{}
+}
+
+func (c *ResponseCookieChecker) Name() string {
+	return "cookies"
+}
+
+func (c *ResponseCookieChecker) Check(t models.TestInterface, result *models.Result) ([]error, error) {
+	// test cookies set by the response with the expected ones
+	expectedCookies, ok := t.GetResponseCookies(result.ResponseStatusCode)
+	if !ok || len(expectedCookies) == 0 {
+		return nil, nil
+	}
+
+	// the last cookie with the name wins, as in a browser
+	actualCookies := make(map[string]*http.Cookie)
+	for _, cookie := range (&http.Response{Header: result.ResponseHeaders}).Cookies() {
+		actualCookies[cookie.Name] = cookie
+	}
+
+	var errs []error
+	for name, expected := range expectedCookies {
+		actual, ok := actualCookies[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("response does not set expected cookie %s", name))
+			continue
+		}
+		if expected == nil {
+			continue
+		}
+		for _, err := range compareCookie(expected, actual) {
+			errs = append(errs, fmt.Errorf("response cookie %s: %s", name, err))
+		}
+	}
+
+	return errs, nil
+}
+
+func compareCookie(expected *models.CookieAssertion, actual *http.Cookie) []error {
+	var errs []error
+
+	if expected.Value != nil && len(compare.Compare(*expected.Value, actual.Value, compare.CompareParams{})) > 0 {
+		errs = append(errs, fmt.Errorf("value %q does not match expected %q", actual.Value, *expected.Value))
+	}
+	if expected.Path != "" && expected.Path != actual.Path {
+		errs = append(errs, fmt.Errorf("path is %s, expected %s", actual.Path, expected.Path))
+	}
+	if expected.Domain != "" && !strings.EqualFold(strings.TrimPrefix(expected.Domain, "."), actual.Domain) {
+		errs = append(errs, fmt.Errorf("domain is %s, expected %s", actual.Domain, expected.Domain))
+	}
+	if expected.HttpOnly != nil && *expected.HttpOnly != actual.HttpOnly {
+		errs = append(errs, fmt.Errorf("HttpOnly is %t, expected %t", actual.HttpOnly, *expected.HttpOnly))
+	}
+	if expected.Secure != nil && *expected.Secure != actual.Secure {
+		errs = append(errs, fmt.Errorf("Secure is %t, expected %t", actual.Secure, *expected.Secure))
+	}
+	if expected.SameSite != "" && !strings.EqualFold(expected.SameSite, sameSiteName(actual.SameSite)) {
+		errs = append(errs, fmt.Errorf("SameSite is %s, expected %s", sameSiteName(actual.SameSite), expected.SameSite))
+	}
+	session := actual.RawExpires == "" && actual.MaxAge == 0
+	if expected.Session != nil && *expected.Session != session {
+		if session {
+			errs = append(errs, fmt.Errorf("cookie is a session one, expected Expires or Max-Age"))
+		} else {
+			errs = append(errs, fmt.Errorf("cookie has Expires or Max-Age, expected a session one"))
+		}
+	}
+	if expected.MaxAge != nil {
+		if actual.MaxAge == 0 {
+			errs = append(errs, fmt.Errorf("Max-Age is not set, expected %d", *expected.MaxAge))
+		} else if maxAge := maxAgeValue(actual); maxAge != *expected.MaxAge {
+			errs = append(errs, fmt.Errorf("Max-Age is %d, expected %d", maxAge, *expected.MaxAge))
+		}
+	}
+	if expected.Expires != nil && len(compare.Compare(*expected.Expires, actual.RawExpires, compare.CompareParams{})) > 0 {
+		errs = append(errs, fmt.Errorf("Expires %q does not match expected %q", actual.RawExpires, *expected.Expires))
+	}
+
+	return errs
+}
+
+// maxAgeValue returns Max-Age of the cookie, it's zero for the deleted cookie
+func maxAgeValue(cookie *http.Cookie) int {
+	// net/http reports Max-Age=0 and negative values as -1
+	if cookie.MaxAge < 0 {
+		return 0
+	}
+	return cookie.MaxAge
+}
+
+func sameSiteName(s http.SameSite) string {
+	switch s {
+	case http.SameSiteLaxMode:
+		return "Lax"
+	case http.SameSiteStrictMode:
+		return "Strict"
+	case http.SameSiteNoneMode:
+		return "None"
+	case http.SameSiteDefaultMode:
+		return "Default"
+	case 0:
+		return "not set"
+	default:
+		return strconv.Itoa(int(s))
+	}
+}
@@ -0,0 +1,213 @@
+package response_cookie
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+
+	"github.com/rezikovka/gonkey/models"
+	"github.com/rezikovka/gonkey/testloader/yaml_file"
+)
+
+// check checks Set-Cookie headers by the cookie assertions written in YAML
+func check(t *testing.T, expected string, setCookies ...string) []string {
+	var cookies map[string]*models.CookieAssertion
+	require.NoError(t, yaml.Unmarshal([]byte(expected), &cookies))
+
+	test := &yaml_file.Test{TestDefinition: yaml_file.TestDefinition{
+		ResponseCookies: map[int]map[string]*models.CookieAssertion{200: cookies},
+	}}
+	result := &models.Result{
+		ResponseStatusCode: 200,
+		ResponseHeaders:    http.Header{"Set-Cookie": setCookies},
+	}
+
+	errs, err := NewChecker().Check(test, result)
+	require.NoError(t, err)
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	return messages
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name           string
+		expected       string
+		setCookies     []string
+		expectedErrors []string
+	}{
+		{
+			name:       "cookie is set",
+			expected:   "lang: {}",
+			setCookies: []string{"lang=en"},
+		},
+		{
+			name:           "cookie is not set",
+			expected:       "lang:",
+			setCookies:     []string{"session=abc"},
+			expectedErrors: []string{"response does not set expected cookie lang"},
+		},
+		{
+			name:       "value",
+			expected:   "session: {value: abc}",
+			setCookies: []string{"session=abc"},
+		},
+		{
+			name:       "value matches regexp",
+			expected:   "session: {value: '$matchRegexp(^[a-f0-9]{4}$)'}",
+			setCookies: []string{"session=a1b2"},
+		},
+		{
+			name:           "value does not match",
+			expected:       "session: {value: abc}",
+			setCookies:     []string{"session=xyz"},
+			expectedErrors: []string{`response cookie session: value "xyz" does not match expected "abc"`},
+		},
+		{
+			name:       "empty value",
+			expected:   `session: {value: ""}`,
+			setCookies: []string{"session=; Max-Age=0"},
+		},
+		{
+			name:           "value is not empty",
+			expected:       `session: {value: ""}`,
+			setCookies:     []string{"session=abc"},
+			expectedErrors: []string{`response cookie session: value "abc" does not match expected ""`},
+		},
+		{
+			name:       "the last cookie with the name wins",
+			expected:   "session: {value: second}",
+			setCookies: []string{"session=first", "session=second"},
+		},
+		{
+			name:       "attributes",
+			expected:   "session: {path: /api, domain: .example.com, httpOnly: true, secure: true, sameSite: lax}",
+			setCookies: []string{"session=abc; Path=/api; Domain=example.com; HttpOnly; Secure; SameSite=Lax"},
+		},
+		{
+			name:       "attributes which must be unset",
+			expected:   "session: {httpOnly: false, secure: false}",
+			setCookies: []string{"session=abc"},
+		},
+		{
+			name:       "attributes do not match",
+			expected:   "session: {path: /api, domain: example.com, httpOnly: true, secure: true, sameSite: Strict}",
+			setCookies: []string{"session=abc; Path=/; Domain=example.org; SameSite=None"},
+			expectedErrors: []string{
+				"response cookie session: path is /, expected /api",
+				"response cookie session: domain is example.org, expected example.com",
+				"response cookie session: HttpOnly is false, expected true",
+				"response cookie session: Secure is false, expected true",
+				"response cookie session: SameSite is None, expected Strict",
+			},
+		},
+		{
+			name:           "SameSite is not set",
+			expected:       "session: {sameSite: Lax}",
+			setCookies:     []string{"session=abc"},
+			expectedErrors: []string{"response cookie session: SameSite is not set, expected Lax"},
+		},
+		{
+			name:       "session cookie",
+			expected:   "session: {session: true}",
+			setCookies: []string{"session=abc"},
+		},
+		{
+			name:           "session cookie has Max-Age",
+			expected:       "session: {session: true}",
+			setCookies:     []string{"session=abc; Max-Age=60"},
+			expectedErrors: []string{"response cookie session: cookie has Expires or Max-Age, expected a session one"},
+		},
+		{
+			name:       "deleted cookie is not a session one",
+			expected:   "session: {session: false}",
+			setCookies: []string{"session=; Max-Age=0"},
+		},
+		{
+			name:           "persistent cookie is a session one",
+			expected:       "session: {session: false}",
+			setCookies:     []string{"session=abc"},
+			expectedErrors: []string{"response cookie session: cookie is a session one, expected Expires or Max-Age"},
+		},
+		{
+			name:       "Max-Age",
+			expected:   "session: {maxAge: 3600}",
+			setCookies: []string{"session=abc; Max-Age=3600"},
+		},
+		{
+			name:           "Max-Age does not match",
+			expected:       "session: {maxAge: 3600}",
+			setCookies:     []string{"session=abc; Max-Age=60"},
+			expectedErrors: []string{"response cookie session: Max-Age is 60, expected 3600"},
+		},
+		{
+			name:       "Max-Age=0 deletes the cookie",
+			expected:   "session: {maxAge: 0}",
+			setCookies: []string{"session=; Max-Age=0"},
+		},
+		{
+			name:       "negative Max-Age deletes the cookie",
+			expected:   "session: {maxAge: 0}",
+			setCookies: []string{"session=; Max-Age=-1"},
+		},
+		{
+			name:           "Max-Age is not set",
+			expected:       "session: {maxAge: 0}",
+			setCookies:     []string{"session=abc"},
+			expectedErrors: []string{"response cookie session: Max-Age is not set, expected 0"},
+		},
+		{
+			name:           "deleted cookie has no positive Max-Age",
+			expected:       "session: {maxAge: 60}",
+			setCookies:     []string{"session=; Max-Age=0"},
+			expectedErrors: []string{"response cookie session: Max-Age is 0, expected 60"},
+		},
+		{
+			name:       "Expires",
+			expected:   "session: {expires: 'Thu, 01 Jan 1970 00:00:00 GMT'}",
+			setCookies: []string{"session=; Expires=Thu, 01 Jan 1970 00:00:00 GMT"},
+		},
+		{
+			name:       "Expires matches regexp",
+			expected:   "session: {expires: '$matchRegexp(GMT$)'}",
+			setCookies: []string{"session=abc; Expires=Wed, 21 Oct 2037 07:28:00 GMT"},
+		},
+		{
+			name:           "Expires does not match",
+			expected:       "session: {expires: 'Thu, 01 Jan 1970 00:00:00 GMT'}",
+			setCookies:     []string{"session=abc; Expires=Wed, 21 Oct 2037 07:28:00 GMT"},
+			expectedErrors: []string{`response cookie session: Expires "Wed, 21 Oct 2037 07:28:00 GMT" does not match expected "Thu, 01 Jan 1970 00:00:00 GMT"`},
+		},
+		{
+			name:       "no Expires",
+			expected:   `session: {expires: ""}`,
+			setCookies: []string{"session=abc; Max-Age=60"},
+		},
+		{
+			name:           "Expires is set",
+			expected:       `session: {expires: ""}`,
+			setCookies:     []string{"session=abc; Expires=Wed, 21 Oct 2037 07:28:00 GMT"},
+			expectedErrors: []string{`response cookie session: Expires "Wed, 21 Oct 2037 07:28:00 GMT" does not match expected ""`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedErrors, check(t, tt.expected, tt.setCookies...))
+		})
+	}
+}
+
+func TestCheckWithoutExpectedCookies(t *testing.T) {
+	errs, err := NewChecker().Check(&yaml_file.Test{}, &models.Result{
+		ResponseStatusCode: 200,
+		ResponseHeaders:    http.Header{"Set-Cookie": {"session=abc"}},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, errs)
+}
@@ -1,31 +1,50 @@
 package response_db
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/rezikovka/gonkey/checker"
+	"github.com/rezikovka/gonkey/compare"
+	"github.com/rezikovka/gonkey/fixtures"
 	"github.com/rezikovka/gonkey/models"
 
 	"github.com/fatih/color"
-	"github.com/kylelemons/godebug/pretty"
 )
 
 type ResponseDbChecker struct {
 	checker.CheckerInterface
 
-	db *sql.DB
+	db     *sql.DB
+	dbType fixtures.DbType
 }
 
+// NewChecker makes the checker of PostgreSQL database
 func NewChecker(dbConnect *sql.DB) checker.CheckerInterface {
+	return NewCheckerWithDbType(dbConnect, fixtures.Postgres)
+}
+
+// NewCheckerWithDbType makes the checker which queries the database of the given type
+func NewCheckerWithDbType(dbConnect *sql.DB, dbType fixtures.DbType) checker.CheckerInterface {
 	return &ResponseDbChecker{
-		db: dbConnect,
+		db:     dbConnect,
+		dbType: dbType,
 	}
 }
 
+func (c *ResponseDbChecker) Name() string {
+	return "db"
+}
+
 func (c *ResponseDbChecker) Check(t models.TestInterface, result *models.Result) ([]error, error) {
+	return c.CheckContext(context.Background(), t, result)
+}
+
+func (c *ResponseDbChecker) CheckContext(ctx context.Context, t models.TestInterface, result *models.Result) ([]error, error) {
 	var errors []error
 
 	// don't check if there are no data for db test
@@ -50,18 +69,13 @@ func (c *ResponseDbChecker) Check(t models.TestInterface, result *models.Result)
 	}
 
 	// get DB response
-	actualDbResponse, err := newQuery(t.DbQueryString(), c.db)
+	actualDbResponse, err := newQuery(ctx, t.DbQueryString(), c.db, c.dbType)
 	if err != nil {
 		return nil, err
 	}
 	result.DbQuery = t.DbQueryString()
 	result.DbResponse = actualDbResponse
 
-	// compare responses length
-	if err := compareDbResponseLength(t.DbResponseJson(), result.DbResponse, result.DbQuery); err != nil {
-		errors = append(errors, err)
-		return errors, nil
-	}
 	// compare responses as json lists
 	checkErrors, err := compareDbResp(t, result)
 	if err != nil {
@@ -73,13 +87,12 @@ func (c *ResponseDbChecker) Check(t models.TestInterface, result *models.Result)
 }
 
 func compareDbResp(t models.TestInterface, result *models.Result) ([]error, error) {
-	var errors []error
-	var actualJson interface{}
-	var expectedJson interface{}
+	expectedRows := make([]interface{}, len(t.DbResponseJson()))
+	actualRows := make([]interface{}, len(result.DbResponse))
 
 	for i, row := range t.DbResponseJson() {
 		// decode expected row
-		if err := json.Unmarshal([]byte(row), &expectedJson); err != nil {
+		if err := json.Unmarshal([]byte(row), &expectedRows[i]); err != nil {
 			return nil, fmt.Errorf(
 				"invalid JSON in the expected DB response for test %s:\n row #%d:\n %s\n error:\n%s",
 				t.GetName(),
@@ -88,64 +101,53 @@ func compareDbResp(t models.TestInterface, result *models.Result) ([]error, erro
 				err.Error(),
 			)
 		}
+	}
+	for i, row := range result.DbResponse {
 		// decode actual row
-		if err := json.Unmarshal([]byte(result.DbResponse[i]), &actualJson); err != nil {
+		if err := json.Unmarshal([]byte(row), &actualRows[i]); err != nil {
 			return nil, fmt.Errorf(
 				"invalid JSON in the actual DB response for test %s:\n row #%d:\n %s\n error:\n%s",
 				t.GetName(),
 				i,
-				result.DbResponse[i],
+				row,
 				err.Error(),
 			)
 		}
-
-		// compare responses row as jsons
-		if err := compareDbResponseRow(expectedJson, actualJson, result.DbQuery); err != nil {
-			errors = append(errors, err)
-		}
 	}
 
-	return errors, nil
-}
+	// rows are compared in the order of the query result, columns must match exactly
+	params := compare.CompareParams{DisallowExtraFields: true}
+	errs := compare.Compare(expectedRows, actualRows, params)
 
-func compareDbResponseRow(expected, actual, query interface{}) error {
-	var err error
-
-	if diff := pretty.Compare(expected, actual); diff != "" {
-		err = fmt.Errorf(
-			"items in database do not match (-expected: +actual):\n     test query:\n%s\n    result diff:\n%s",
-			color.CyanString("%v", query),
-			color.CyanString("%v", diff),
-		)
+	title := fmt.Sprintf("items in database do not match\n     test query:\n%s\n    result diff", color.CyanString("%v", result.DbQuery))
+	if err := compare.NewDiffError(title, expectedRows, actualRows, params, errs); err != nil {
+		return []error{err}, nil
 	}
-	return err
+	return nil, nil
 }
 
-func compareDbResponseLength(expected, actual []string, query interface{}) error {
-	var err error
+// newQuery returns rows of the query result as JSON objects
+func newQuery(ctx context.Context, dbQuery string, db *sql.DB, dbType fixtures.DbType) ([]string, error) {
+	if idx := strings.IndexByte(dbQuery, ';'); idx >= 0 {
+		dbQuery = dbQuery[:idx]
+	}
 
-	if len(expected) != len(actual) {
-		err = fmt.Errorf(
-			"quantity of items in database do not match (-expected: %s +actual: %s)\n     test query:\n%s\n    result diff:\n%s",
-			color.CyanString("%v", len(expected)),
-			color.CyanString("%v", len(actual)),
-			color.CyanString("%v", query),
-			color.CyanString("%v", pretty.Compare(expected, actual)),
-		)
+	switch dbType {
+	case fixtures.Mysql:
+		return newColumnsQuery(ctx, dbQuery, db, mysqlJsonValue)
+	case fixtures.Sqlite:
+		return newColumnsQuery(ctx, dbQuery, db, sqliteJsonValue)
+	default:
+		return newPostgresQuery(ctx, dbQuery, db)
 	}
-	return err
 }
 
-func newQuery(dbQuery string, db *sql.DB) ([]string, error) {
+func newPostgresQuery(ctx context.Context, dbQuery string, db *sql.DB) ([]string, error) {
 
 	var dbResponse []string
 	var jsonString string
 
-	if idx := strings.IndexByte(dbQuery, ';'); idx >= 0 {
-		dbQuery = dbQuery[:idx]
-	}
-
-	rows, err := db.Query(fmt.Sprintf("SELECT row_to_json(rows) FROM (%s) rows;", dbQuery))
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT row_to_json(rows) FROM (%s) rows;", dbQuery))
 	if err != nil {
 		return nil, err
 	}
@@ -165,3 +167,98 @@ func newQuery(dbQuery string, db *sql.DB) ([]string, error) {
 
 	return dbResponse, nil
 }
+
+// newColumnsQuery encodes rows to JSON by column types, it's used for databases
+// which have no function converting the whole row like row_to_json in PostgreSQL
+func newColumnsQuery(ctx context.Context, dbQuery string, db *sql.DB, jsonValue func(string, interface{}) interface{}) ([]string, error) {
+
+	var dbResponse []string
+
+	rows, err := db.QueryContext(ctx, dbQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			row[column.Name()] = jsonValue(column.DatabaseTypeName(), values[i])
+		}
+
+		jsonRow, err := json.Marshal(row)
+		if err != nil {
+			return nil, err
+		}
+		dbResponse = append(dbResponse, string(jsonRow))
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return dbResponse, nil
+}
+
+// mysqlJsonValue converts the value of the column to the JSON value like row_to_json does in PostgreSQL
+func mysqlJsonValue(typeName string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		switch strings.TrimPrefix(typeName, "UNSIGNED ") {
+		case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "YEAR", "DECIMAL", "FLOAT", "DOUBLE":
+			return json.Number(v)
+		case "JSON":
+			if json.Valid(v) {
+				return json.RawMessage(v)
+			}
+		case "DATETIME", "TIMESTAMP":
+			// the same format as PostgreSQL timestamps have in JSON
+			return strings.Replace(string(v), " ", "T", 1)
+		}
+		return string(v)
+	case time.Time:
+		// DSN has parseTime=true
+		return v.Format("2006-01-02T15:04:05.999999")
+	default:
+		return v
+	}
+}
+
+// sqliteJsonValue converts the value of the column to the JSON value like row_to_json does in PostgreSQL
+func sqliteJsonValue(typeName string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return sqliteJsonValue(typeName, string(v))
+	case string:
+		if strings.EqualFold(typeName, "JSON") && json.Valid([]byte(v)) {
+			return json.RawMessage(v)
+		}
+		return v
+	case int64:
+		if strings.EqualFold(typeName, "BOOLEAN") {
+			return v != 0
+		}
+		return v
+	case time.Time:
+		// the driver parses columns declared as DATE, DATETIME and TIMESTAMP
+		return v.Format("2006-01-02T15:04:05.999999")
+	default:
+		// reals and NULL
+		return v
+	}
+}
@@ -0,0 +1,146 @@
+package response_db
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rezikovka/gonkey/fixtures"
+	"github.com/rezikovka/gonkey/models"
+	"github.com/rezikovka/gonkey/testloader/yaml_file"
+)
+
+func TestNewQueryPostgres(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT row_to_json(rows) FROM (SELECT id, name FROM users) rows;").
+		WillReturnRows(sqlmock.NewRows([]string{"row_to_json"}).
+			AddRow(`{"id":1,"name":"a"}`).
+			AddRow(`{"id":2,"name":"b"}`))
+
+	rows, err := newQuery(context.Background(), "SELECT id, name FROM users; DROP TABLE users", db, fixtures.Postgres)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"id":1,"name":"a"}`, `{"id":2,"name":"b"}`}, rows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNewQueryMysql(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	columns := []*sqlmock.Column{
+		mock.NewColumn("id").OfType("UNSIGNED BIGINT", []byte{}),
+		mock.NewColumn("name").OfType("VARCHAR", []byte{}),
+		mock.NewColumn("price").OfType("DECIMAL", []byte{}),
+		mock.NewColumn("data").OfType("JSON", []byte{}),
+		mock.NewColumn("created_at").OfType("DATETIME", []byte{}),
+		mock.NewColumn("deleted_at").OfType("DATETIME", []byte{}),
+	}
+	mock.ExpectQuery("SELECT * FROM orders").
+		WillReturnRows(sqlmock.NewRowsWithColumnDefinition(columns...).
+			AddRow([]byte("18446744073709551615"), []byte("12"), []byte("10.50"), []byte(`{"a": [1, 2]}`), []byte("2020-01-02 03:04:05"), nil))
+
+	rows, err := newQuery(context.Background(), "SELECT * FROM orders;", db, fixtures.Mysql)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.JSONEq(t, `{
+		"id": 18446744073709551615,
+		"name": "12",
+		"price": 10.50,
+		"data": {"a": [1, 2]},
+		"created_at": "2020-01-02T03:04:05",
+		"deleted_at": null
+	}`, rows[0])
+	// big numbers don't lose precision
+	assert.Contains(t, rows[0], `"id":18446744073709551615`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMysqlJsonValue(t *testing.T) {
+	tests := []struct {
+		typeName string
+		value    interface{}
+		expected interface{}
+	}{
+		{"INT", []byte("-5"), json.Number("-5")},
+		{"UNSIGNED TINYINT", []byte("255"), json.Number("255")},
+		{"DOUBLE", []byte("1.5e+10"), json.Number("1.5e+10")},
+		{"YEAR", []byte("2020"), json.Number("2020")},
+		{"JSON", []byte(`{"a":1}`), json.RawMessage(`{"a":1}`)},
+		{"JSON", []byte(`{broken`), `{broken`},
+		{"TIMESTAMP", []byte("2020-01-02 03:04:05.123"), "2020-01-02T03:04:05.123"},
+		{"VARCHAR", []byte("2020-01-02 03:04:05"), "2020-01-02 03:04:05"},
+		{"BLOB", []byte("abc"), "abc"},
+		{"DATETIME", time.Date(2020, 1, 2, 3, 4, 5, 600000000, time.UTC), "2020-01-02T03:04:05.6"},
+		{"BIGINT", int64(7), int64(7)},
+		{"VARCHAR", nil, nil},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, mysqlJsonValue(tt.typeName, tt.value), "%s %#v", tt.typeName, tt.value)
+	}
+}
+
+func TestCheckMysql(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	columns := []*sqlmock.Column{
+		mock.NewColumn("id").OfType("INT", []byte{}),
+		mock.NewColumn("status").OfType("VARCHAR", []byte{}),
+	}
+	query := "SELECT id, status FROM orders ORDER BY id"
+	for i := 0; i < 2; i++ {
+		mock.ExpectQuery(query).
+			WillReturnRows(sqlmock.NewRowsWithColumnDefinition(columns...).
+				AddRow([]byte("1"), []byte("new")).
+				AddRow([]byte("2"), []byte("paid")))
+	}
+
+	test := &yaml_file.Test{DbQuery: query}
+	c := NewCheckerWithDbType(db, fixtures.Mysql)
+
+	test.DbResponse = []string{`{"id": 1, "status": "new"}`, `{"id": 2, "status": "paid"}`}
+	errs, err := c.Check(test, &models.Result{})
+	require.NoError(t, err)
+	assert.Empty(t, errs)
+
+	test.DbResponse = []string{`{"id": 1, "status": "new"}`, `{"id": "2", "status": "paid"}`}
+	errs, err = c.Check(test, &models.Result{})
+	require.NoError(t, err)
+	assert.Len(t, errs, 1)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSqliteJsonValue(t *testing.T) {
+	tests := []struct {
+		typeName string
+		value    interface{}
+		expected interface{}
+	}{
+		{"INTEGER", int64(5), int64(5)},
+		{"BOOLEAN", int64(1), true},
+		{"boolean", int64(0), false},
+		{"REAL", 1.5, 1.5},
+		{"JSON", `{"a":1}`, json.RawMessage(`{"a":1}`)},
+		{"JSON", `{broken`, `{broken`},
+		{"TEXT", `{"a":1}`, `{"a":1}`},
+		{"BLOB", []byte("ab"), "ab"},
+		{"DATETIME", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), "2020-01-02T03:04:05"},
+		{"TEXT", nil, nil},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, sqliteJsonValue(tt.typeName, tt.value), "%s %#v", tt.typeName, tt.value)
+	}
+}
@@ -3,6 +3,7 @@ package response_header
 import (
 	"fmt"
 	"net/textproto"
+	"strconv"
 
 	"github.com/rezikovka/gonkey/checker"
 	"github.com/rezikovka/gonkey/compare"
@@ -17,6 +18,10 @@ func NewChecker() checker.CheckerInterface {
 	return &ResponseHeaderChecker{}
 }
 
+func (c *ResponseHeaderChecker) Name() string {
+	return "headers"
+}
+
 func (c *ResponseHeaderChecker) Check(t models.TestInterface, result *models.Result) ([]error, error) {
 	// test response headers with the expected headers
 	expectedHeaders, ok := t.GetResponseHeaders(result.ResponseStatusCode)
@@ -34,8 +39,7 @@ func (c *ResponseHeaderChecker) Check(t models.TestInterface, result *models.Res
 		}
 		found := false
 		for _, actualValue := range actualValues {
-			e := compare.Compare(v, actualValue, compare.CompareParams{})
-			if len(e) == 0 {
+			if headerMatches(v, actualValue) {
 				found = true
 			}
 		}
@@ -46,3 +50,15 @@ func (c *ResponseHeaderChecker) Check(t models.TestInterface, result *models.Res
 
 	return errs, nil
 }
+
+// headerMatches compares the value of the header, the value containing a number is compared
+// as the number too, so matchers of numbers like $matchType(integer) can check headers
+func headerMatches(expected, actual string) bool {
+	if len(compare.Compare(expected, actual, compare.CompareParams{})) == 0 {
+		return true
+	}
+	if n, err := strconv.ParseFloat(actual, 64); err == nil {
+		return len(compare.Compare(expected, n, compare.CompareParams{})) == 0
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package response_header
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rezikovka/gonkey/models"
+	"github.com/rezikovka/gonkey/testloader/yaml_file"
+)
+
+func TestCheckNumericHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		actual   string
+		passed   bool
+	}{
+		{name: "number", expected: "$matchType(number)", actual: "4.5", passed: true},
+		{name: "integer", expected: "$matchType(integer)", actual: "42", passed: true},
+		{name: "not integer", expected: "$matchType(integer)", actual: "4.5"},
+		{name: "not number", expected: "$matchType(number)", actual: "many"},
+		{name: "approx", expected: "$approx(100, 5)", actual: "103", passed: true},
+		{name: "string", expected: "$matchType(string)", actual: "42", passed: true},
+		{name: "plain value", expected: "42", actual: "42", passed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test := &yaml_file.Test{ResponseHeaders: map[int]map[string]string{200: {"X-Count": tt.expected}}}
+			result := &models.Result{
+				ResponseStatusCode: 200,
+				ResponseHeaders:    http.Header{"X-Count": {tt.actual}},
+			}
+
+			errs, err := NewChecker().Check(test, result)
+			require.NoError(t, err)
+			if tt.passed {
+				assert.Empty(t, errs)
+			} else {
+				assert.Len(t, errs, 1)
+			}
+		})
+	}
+}
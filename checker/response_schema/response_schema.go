@@ -34,6 +34,10 @@ func NewChecker(specLocation string) checker.CheckerInterface {
 	}
 }
 
+func (c *ResponseSchemaChecker) Name() string {
+	return "schema"
+}
+
 func (c *ResponseSchemaChecker) Check(t models.TestInterface, result *models.Result) ([]error, error) {
 	// decode actual body
 	var actual interface{}
@@ -0,0 +1,77 @@
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var captureExprRx = regexp.MustCompile(`(?s)^\$capture\(\s*([A-Za-z_][A-Za-z0-9_]*)\s*(?:,(.*))?\)$`)
+
+// compareCapture checks the value by the pattern of $capture(name, pattern) and stores it to the captures.
+// The pattern is a matcher expression or a regex, the value matches any pattern if it's empty.
+// If the regex has groups, the first group is stored instead of the whole value.
+// The name can be captured several times only with the same value.
+func compareCapture(path, name, pattern string, expected, actual interface{}, params *CompareParams) []error {
+	pattern = strings.TrimSpace(pattern)
+	value := captureValue(actual)
+
+	switch {
+	case pattern == "" || params.IgnoreValues:
+	case strings.HasPrefix(pattern, "$"):
+		matcher, args, ok := findMatcher(pattern)
+		if !ok {
+			return []error{makeError(path, fmt.Sprintf("invalid matcher: unknown matcher %s", pattern), expected, actual)}
+		}
+		if errs := match(path, matcher, args, expected, actual); len(errs) > 0 {
+			return errs
+		}
+	default:
+		rx, err := regexp.Compile(pattern)
+		if err != nil {
+			return []error{makeError(path, fmt.Sprintf("invalid matcher: can not compile regex: %s", err), expected, actual)}
+		}
+		if !isScalarType(getType(actual)) {
+			return []error{makeError(path, "types do not match", "scalar", getType(actual))}
+		}
+		matches := rx.FindStringSubmatch(value)
+		if matches == nil {
+			return []error{makeError(path, "value does not match regex", expected, actual)}
+		}
+		if len(matches) > 1 {
+			value = matches[1]
+		}
+	}
+
+	if params.Captures != nil {
+		// the name captured several times, e.g. in items of the array, must have the same value
+		if captured, ok := params.Captures[name]; ok && captured != value {
+			return []error{makeError(path, fmt.Sprintf("value differs from the value captured to %s before", name), captured, value)}
+		}
+		params.Captures[name] = value
+	}
+	return nil
+}
+
+// captureValue returns the value as it's substituted into variables,
+// arrays and objects are encoded as JSON
+func captureValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	if !isScalarType(getType(value)) {
+		if data, err := json.Marshal(value); err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprintf("%v", value)
+}
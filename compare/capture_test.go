@@ -0,0 +1,156 @@
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapture(t *testing.T) {
+	tests := []struct {
+		name             string
+		expected         string
+		actual           string
+		params           CompareParams
+		expectedCaptures map[string]string
+		expectedErrors   []string
+	}{
+		{
+			name:             "any value",
+			expected:         `{"id": "$capture(id)", "name": "book"}`,
+			actual:           `{"id": 42, "name": "book"}`,
+			expectedCaptures: map[string]string{"id": "42"},
+		},
+		{
+			name:             "regex",
+			expected:         `{"id": "$capture(id, ^[0-9]+$)"}`,
+			actual:           `{"id": "123"}`,
+			expectedCaptures: map[string]string{"id": "123"},
+		},
+		{
+			name:             "group of regex",
+			expected:         `{"number": "$capture(number, ^ORD-(\\d+)$)"}`,
+			actual:           `{"number": "ORD-77"}`,
+			expectedCaptures: map[string]string{"number": "77"},
+		},
+		{
+			name:           "regex doesn't match",
+			expected:       `{"id": "$capture(id, ^[0-9]+$)"}`,
+			actual:         `{"id": "abc"}`,
+			expectedErrors: []string{"value does not match regex"},
+		},
+		{
+			name:           "regex of object",
+			expected:       `{"id": "$capture(id, ^[0-9]+$)"}`,
+			actual:         `{"id": {"value": 1}}`,
+			expectedErrors: []string{"types do not match"},
+		},
+		{
+			name:           "invalid regex",
+			expected:       `{"id": "$capture(id, [)"}`,
+			actual:         `{"id": "1"}`,
+			expectedErrors: []string{"invalid matcher: can not compile regex: error parsing regexp: missing closing ]: `[`"},
+		},
+		{
+			name:             "matcher",
+			expected:         `{"id": "$capture(id, $matchType(uuid))"}`,
+			actual:           `{"id": "550e8400-e29b-41d4-a716-446655440000"}`,
+			expectedCaptures: map[string]string{"id": "550e8400-e29b-41d4-a716-446655440000"},
+		},
+		{
+			name:           "matcher doesn't match",
+			expected:       `{"id": "$capture(id, $matchType(uuid))"}`,
+			actual:         `{"id": "1"}`,
+			expectedErrors: []string{"types do not match"},
+		},
+		{
+			name:           "unknown matcher",
+			expected:       `{"id": "$capture(id, $matchNothing)"}`,
+			actual:         `{"id": "1"}`,
+			expectedErrors: []string{"invalid matcher: unknown matcher $matchNothing"},
+		},
+		{
+			name:             "object and array are captured as JSON",
+			expected:         `{"customer": "$capture(customer)", "tags": "$capture(tags)"}`,
+			actual:           `{"customer": {"name": "Bob", "id": 1}, "tags": ["a", "b"]}`,
+			expectedCaptures: map[string]string{"customer": `{"id":1,"name":"Bob"}`, "tags": `["a","b"]`},
+		},
+		{
+			name:             "null and booleans",
+			expected:         `{"deleted": "$capture(deleted)", "parent": "$capture(parent)"}`,
+			actual:           `{"deleted": false, "parent": null}`,
+			expectedCaptures: map[string]string{"deleted": "false", "parent": ""},
+		},
+		{
+			name:             "inside arrays and objects",
+			expected:         `{"orders": [{"id": "$capture(first)"}, {"id": 2, "items": [{"sku": "$capture(sku, ^SKU-(.+)$)"}]}]}`,
+			actual:           `{"orders": [{"id": 1}, {"id": 2, "items": [{"sku": "SKU-X1"}]}]}`,
+			expectedCaptures: map[string]string{"first": "1", "sku": "X1"},
+		},
+		{
+			name:             "the same value is captured several times",
+			expected:         `[{"user": "$capture(user)"}, {"user": "$capture(user)"}]`,
+			actual:           `[{"user": "bob"}, {"user": "bob"}]`,
+			expectedCaptures: map[string]string{"user": "bob"},
+		},
+		{
+			name:             "conflicting values",
+			expected:         `[{"user": "$capture(user)"}, {"user": "$capture(user)"}]`,
+			actual:           `[{"user": "bob"}, {"user": "alice"}]`,
+			expectedCaptures: map[string]string{"user": "bob"},
+			expectedErrors:   []string{"value differs from the value captured to user before"},
+		},
+		{
+			name:             "values are captured although other values don't match",
+			expected:         `{"id": "$capture(id)", "name": "book"}`,
+			actual:           `{"id": 1, "name": "pen"}`,
+			expectedCaptures: map[string]string{"id": "1"},
+			expectedErrors:   []string{"values do not match"},
+		},
+		{
+			name:             "pattern is not checked if values are ignored",
+			expected:         `{"id": "$capture(id, ^[0-9]+$)"}`,
+			actual:           `{"id": "abc"}`,
+			params:           CompareParams{IgnoreValues: true},
+			expectedCaptures: map[string]string{"id": "abc"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captures := make(map[string]string)
+			tt.params.Captures = captures
+			errs := Compare(decode(t, tt.expected), decode(t, tt.actual), tt.params)
+
+			var messages []string
+			for _, err := range errs {
+				require.IsType(t, &mismatchError{}, err)
+				messages = append(messages, err.(*mismatchError).msg)
+			}
+			assert.Equal(t, tt.expectedErrors, messages)
+			if tt.expectedCaptures == nil {
+				tt.expectedCaptures = map[string]string{}
+			}
+			assert.Equal(t, tt.expectedCaptures, captures)
+		})
+	}
+}
+
+func TestCaptureOfConflictingValue(t *testing.T) {
+	captures := map[string]string{"user": "bob"}
+	errs := Compare(decode(t, `{"user": "$capture(user)"}`), decode(t, `{"user": "alice"}`), CompareParams{Captures: captures})
+	require.Len(t, errs, 1)
+	err := errs[0].(*mismatchError)
+	assert.Equal(t, "$.user", err.path)
+	// the captured value is expected
+	assert.Equal(t, "bob", err.expected)
+	assert.Equal(t, "alice", err.actual)
+	assert.Equal(t, map[string]string{"user": "bob"}, captures)
+}
+
+func TestCaptureWithoutCaptures(t *testing.T) {
+	// the value is checked even if it isn't stored
+	assert.Empty(t, Compare(decode(t, `{"id": "$capture(id, ^1$)"}`), decode(t, `{"id": 1}`), CompareParams{}))
+	assert.Len(t, Compare(decode(t, `{"id": "$capture(id, ^1$)"}`), decode(t, `{"id": 2}`), CompareParams{}), 1)
+}
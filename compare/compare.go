@@ -3,7 +3,6 @@ package compare
 import (
 	"fmt"
 	"reflect"
-	"regexp"
 	"sort"
 	"strings"
 
@@ -14,27 +13,38 @@ type CompareParams struct {
 	IgnoreValues         bool
 	IgnoreArraysOrdering bool
 	DisallowExtraFields  bool
+	Captures             map[string]string // values captured by $capture(name, pattern) are stored here if it's set
 }
 
-type leafsMatchType int
-
-const (
-	pure leafsMatchType = iota
-	regex
-)
-
-var regexExprRx = regexp.MustCompile(`^\$matchRegexp\((.+)\)$`)
-
 // Compare compares values as plain text
 // It can be compared several ways:
 // - Pure values: should be equal
-// - Regex: try to compile 'expected' as regex and match 'actual' with it
-//     It activates on following syntax: $matchRegexp(%EXPECTED_VALUE%)
+// - Matchers: expected value written as $name or $name(args) checks 'actual' by the registered matcher,
+//     e.g. $matchRegexp(%EXPECTED_VALUE%) tries to compile the argument as regex and match 'actual' with it
+// - Captures: $capture(name, pattern) checks 'actual' by the regex or matcher pattern and stores it to params.Captures
 func Compare(expected, actual interface{}, params CompareParams) []error {
 	return compareBranch("$", expected, actual, &params)
 }
 
+// CompareAt compares values like Compare, paths in errors start from the given path
+func CompareAt(path string, expected, actual interface{}, params CompareParams) []error {
+	return compareBranch(path, expected, actual, &params)
+}
+
 func compareBranch(path string, expected, actual interface{}, params *CompareParams) []error {
+	// the matcher checks the actual value of any type
+	if expr, ok := expected.(string); ok {
+		if matches := captureExprRx.FindStringSubmatch(expr); matches != nil {
+			return compareCapture(path, matches[1], matches[2], expected, actual, params)
+		}
+		if matcher, args, ok := findMatcher(expr); ok {
+			if params.IgnoreValues {
+				return nil
+			}
+			return match(path, matcher, args, expected, actual)
+		}
+	}
+
 	expectedType := getType(expected)
 	actualType := getType(actual)
 	var errors []error
@@ -47,7 +57,7 @@ func compareBranch(path string, expected, actual interface{}, params *ComparePar
 
 	// compare scalars
 	if isScalarType(actualType) && !params.IgnoreValues {
-		return compareLeafs(path, expected, actual)
+		return comparePure(path, expected, actual)
 	}
 
 	// compare arrays
@@ -123,24 +133,6 @@ func isScalarType(t string) bool {
 	return !(t == "array" || t == "map")
 }
 
-func compareLeafs(path string, expected, actual interface{}) []error {
-
-	var errors []error
-
-	switch leafMatchType(expected) {
-	case pure:
-		errors = append(errors, comparePure(path, expected, actual)...)
-
-	case regex:
-		errors = append(errors, compareRegex(path, expected, actual)...)
-
-	default:
-		panic("unknown compare type")
-	}
-
-	return errors
-}
-
 func comparePure(path string, expected, actual interface{}) (errors []error) {
 
 	if expected != actual {
@@ -150,66 +142,37 @@ func comparePure(path string, expected, actual interface{}) (errors []error) {
 	return errors
 }
 
-func compareRegex(path string, expected, actual interface{}) (errors []error) {
-
-	regexExpr, ok := expected.(string)
-	if !ok {
-		errors = append(errors, makeError(path, "type mismatch", "string", reflect.TypeOf(expected)))
-		return errors
-	}
-
-	value, ok := actual.(string)
-	if !ok {
-		errors = append(errors, makeError(path, "type mismatch", "string", reflect.TypeOf(actual)))
-		return errors
-	}
-
-	rx, err := regexp.Compile(retrieveRegexStr(regexExpr))
-	if err != nil {
-		errors = append(errors, makeError(path, "can not compile regex", nil, "error"))
-		return errors
-	}
-
-	if !rx.MatchString(value) {
-		errors = append(errors, makeError(path, "value does not match regex", expected, actual))
-		return errors
-	}
-
-	return nil
+// MakeError makes the error in the format of comparison errors
+func MakeError(path, msg string, expected, actual interface{}) error {
+	return makeError(path, msg, expected, actual)
 }
 
-func retrieveRegexStr(expr string) string {
-
-	if matches := regexExprRx.FindStringSubmatch(expr); matches != nil {
-		return matches[1]
-	}
-
-	return ""
+func makeError(path, msg string, expected, actual interface{}) error {
+	return &mismatchError{path: path, msg: msg, expected: expected, actual: actual}
 }
 
-func leafMatchType(expected interface{}) leafsMatchType {
-	val, ok := expected.(string)
-	if !ok {
-		return pure
-	}
-
-	if matches := regexExprRx.FindStringSubmatch(val); matches != nil {
-		return regex
-	}
-
-	return pure
+// mismatchError is the comparison error of the value at the path
+type mismatchError struct {
+	path     string
+	msg      string
+	expected interface{}
+	actual   interface{}
 }
 
-func makeError(path, msg string, expected, actual interface{}) error {
-	return fmt.Errorf(
-		"at path %s %s:\n     expected: %s\n       actual: %s",
-		color.CyanString(path),
-		msg,
-		color.GreenString("%v", expected),
-		color.RedString("%v", actual),
+func (e *mismatchError) Error() string {
+	return fmt.Sprintf(
+		"%s:\n     expected: %s\n       actual: %s",
+		e.short(),
+		color.GreenString("%v", e.expected),
+		color.RedString("%v", e.actual),
 	)
 }
 
+// short describes the error without values
+func (e *mismatchError) short() string {
+	return fmt.Sprintf("at path %s %s", color.CyanString(e.path), e.msg)
+}
+
 // Sort an array with respect of its elements of vary type.
 func sortArray(array interface{}) interface{} {
 	ref := reflect.ValueOf(array)
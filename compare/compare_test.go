@@ -0,0 +1,220 @@
+package compare
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decode parses the JSON value of the test case
+func decode(t *testing.T, s string) interface{} {
+	var value interface{}
+	require.NoError(t, json.Unmarshal([]byte(s), &value))
+	return value
+}
+
+func TestMatchType(t *testing.T) {
+	tests := []struct {
+		expected string
+		actual   string
+		passed   bool
+	}{
+		{expected: "$matchType(string)", actual: `"42"`, passed: true},
+		{expected: "$matchType(string)", actual: `42`},
+		{expected: "$matchType(number)", actual: `4.5`, passed: true},
+		{expected: "$matchType(number)", actual: `"42"`},
+		{expected: "$matchType(integer)", actual: `42`, passed: true},
+		{expected: "$matchType(integer)", actual: `4.5`},
+		{expected: "$matchType(integer)", actual: `"42"`},
+		{expected: "$matchType(boolean)", actual: `false`, passed: true},
+		{expected: "$matchType(boolean)", actual: `"true"`},
+		{expected: "$matchType(null)", actual: `null`, passed: true},
+		{expected: "$matchType(null)", actual: `""`},
+		{expected: "$matchType(array)", actual: `[]`, passed: true},
+		{expected: "$matchType(array)", actual: `{}`},
+		{expected: "$matchType(object)", actual: `{"a": 1}`, passed: true},
+		{expected: "$matchType(object)", actual: `[1]`},
+		{expected: "$matchType(uuid)", actual: `"0b0d6b5e-3f3c-4a7b-9d3e-6f0c2a1b7e4d"`, passed: true},
+		{expected: "$matchType(uuid)", actual: `"0b0d6b5e"`},
+		{expected: "$matchType(datetime)", actual: `"2020-01-02T03:04:05Z"`, passed: true},
+		{expected: "$matchType(datetime)", actual: `"2020-01-02"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected+" "+tt.actual, func(t *testing.T) {
+			errs := Compare(tt.expected, decode(t, tt.actual), CompareParams{})
+			if tt.passed {
+				assert.Empty(t, errs)
+			} else {
+				assert.Len(t, errs, 1)
+			}
+		})
+	}
+}
+
+func TestMatchTypeOfDbValues(t *testing.T) {
+	// values of DB rows may be integers
+	assert.Empty(t, Compare("$matchType(integer)", int64(42), CompareParams{}))
+	assert.Empty(t, Compare("$matchType(number)", 42, CompareParams{}))
+	assert.Len(t, Compare("$matchType(number)", "42", CompareParams{}), 1)
+}
+
+func TestMatchers(t *testing.T) {
+	now := time.Now().UTC()
+	past := now.Add(-time.Hour).Format(time.RFC3339)
+	future := now.Add(time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		name     string
+		expected string
+		actual   string
+		mismatch string
+	}{
+		{name: "any null", expected: "$any", actual: `null`},
+		{name: "any object", expected: "$any", actual: `{"a": 1}`},
+		{name: "notEmpty", expected: "$notEmpty", actual: `"a"`},
+		{name: "notEmpty null", expected: "$notEmpty", actual: `null`, mismatch: "value is empty"},
+		{name: "notEmpty string", expected: "$notEmpty", actual: `""`, mismatch: "value is empty"},
+		{name: "notEmpty array", expected: "$notEmpty", actual: `[]`, mismatch: "value is empty"},
+		{name: "matchRegexp", expected: "$matchRegexp(^[a-z]+$)", actual: `"abc"`},
+		{name: "matchRegexp mismatch", expected: "$matchRegexp(^[a-z]+$)", actual: `"ABC"`, mismatch: "value does not match regex"},
+		{name: "matchRegexp number", expected: "$matchRegexp(^[0-9]+$)", actual: `42`, mismatch: "types do not match"},
+		{name: "matchDate layout", expected: "$matchDate(2006-01-02)", actual: `"2020-01-02"`},
+		{name: "matchDate named layout", expected: "$matchDate(DateTime)", actual: `"2020-01-02 03:04:05"`},
+		{name: "matchDate mismatch", expected: "$matchDate(Date)", actual: `"02.01.2020"`, mismatch: "value does not match date layout"},
+		{name: "matchDate layout with comma", expected: "$matchDate(Jan 2, 2006)", actual: `"Feb 3, 2020"`},
+		{name: "matchDate ± range", expected: "$matchDate(RFC3339, ±2h)", actual: `"` + past + `"`},
+		{name: "matchDate ± range future", expected: "$matchDate(RFC3339, ±2h)", actual: `"` + future + `"`},
+		{name: "matchDate out of ± range", expected: "$matchDate(RFC3339, ±30m)", actual: `"` + past + `"`, mismatch: "date is out of range"},
+		{name: "matchDate - range", expected: "$matchDate(RFC3339, -2h)", actual: `"` + past + `"`},
+		{name: "matchDate - range future", expected: "$matchDate(RFC3339, -2h)", actual: `"` + future + `"`, mismatch: "date is out of range"},
+		{name: "matchDate + range", expected: "$matchDate(RFC3339, +2h)", actual: `"` + future + `"`},
+		{name: "matchDate + range past", expected: "$matchDate(RFC3339, +2h)", actual: `"` + past + `"`, mismatch: "date is out of range"},
+		{name: "approx", expected: "$approx(9.99, 0.01)", actual: `10`},
+		{name: "approx exact", expected: "$approx(10, 0)", actual: `10`},
+		{name: "approx out of tolerance", expected: "$approx(9.99, 0.001)", actual: `10`, mismatch: "value is out of tolerance"},
+		{name: "approx string", expected: "$approx(10, 1)", actual: `"10"`, mismatch: "types do not match"},
+		{name: "oneOf string", expected: `$oneOf(new, "paid")`, actual: `"new"`},
+		{name: "oneOf quoted string", expected: `$oneOf(new, "paid")`, actual: `"paid"`},
+		{name: "oneOf number", expected: "$oneOf(1, 2)", actual: `2`},
+		{name: "oneOf number is not string", expected: "$oneOf(1, 2)", actual: `"2"`, mismatch: "value does not match any option"},
+		{name: "oneOf object", expected: `$oneOf({"a": [1, 2]}, null)`, actual: `{"a": [1, 2]}`},
+		{name: "oneOf null", expected: `$oneOf({"a": [1, 2]}, null)`, actual: `null`},
+		{name: "oneOf string with comma", expected: `$oneOf("a, b", c)`, actual: `"a, b"`},
+		{name: "arrayContains", expected: `$arrayContains("sale", 3)`, actual: `["new", "sale", 3]`},
+		{name: "arrayContains object", expected: `$arrayContains({"id": 1})`, actual: `[{"id": 2}, {"id": 1}]`},
+		{name: "arrayContains missing", expected: `$arrayContains(sale, new)`, actual: `["sale"]`, mismatch: "array does not contain new"},
+		{name: "arrayContains not array", expected: `$arrayContains(sale)`, actual: `"sale"`, mismatch: "types do not match"},
+		{name: "len array", expected: "$len(2)", actual: `[1, 2]`},
+		{name: "len string", expected: "$len(3)", actual: `"абв"`},
+		{name: "len object", expected: "$len(1)", actual: `{"a": 1}`},
+		{name: "len mismatch", expected: "$len(1)", actual: `[1, 2]`, mismatch: "length is 2"},
+		{name: "len number", expected: "$len(1)", actual: `1`, mismatch: "value has no length"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := Compare(tt.expected, decode(t, tt.actual), CompareParams{})
+			if tt.mismatch == "" {
+				assert.Empty(t, errs)
+				return
+			}
+			require.Len(t, errs, 1)
+			assert.Equal(t, tt.mismatch, errs[0].(*mismatchError).msg)
+		})
+	}
+}
+
+func TestInvalidMatchers(t *testing.T) {
+	tests := []struct {
+		expected string
+		err      string
+	}{
+		{expected: "$matchRegexp([)", err: "invalid matcher: can not compile regex: error parsing regexp: missing closing ]: `[`"},
+		{expected: "$matchType(date)", err: "invalid matcher: unknown type date"},
+		{expected: "$matchDate()", err: "invalid matcher: layout is required"},
+		{expected: "$approx(1)", err: "invalid matcher: value and tolerance are required"},
+		{expected: "$approx(one, 1)", err: `invalid matcher: invalid value: strconv.ParseFloat: parsing "one": invalid syntax`},
+		{expected: "$approx(1, small)", err: `invalid matcher: invalid tolerance: strconv.ParseFloat: parsing "small": invalid syntax`},
+		{expected: "$oneOf()", err: "invalid matcher: options are required"},
+		{expected: "$arrayContains()", err: "invalid matcher: elements are required"},
+		{expected: "$len(many)", err: `invalid matcher: invalid length: strconv.Atoi: parsing "many": invalid syntax`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			errs := Compare(tt.expected, "value", CompareParams{})
+			require.Len(t, errs, 1)
+			assert.Equal(t, tt.err, errs[0].(*mismatchError).msg)
+		})
+	}
+}
+
+func TestRegisterMatcher(t *testing.T) {
+	even := func(args string, actual interface{}) (string, error) {
+		n, ok := actual.(float64)
+		if !ok || int(n)%2 != 0 {
+			return "value is not even", nil
+		}
+		return "", nil
+	}
+	RegisterMatcher("even", even)
+	defer func() {
+		matchersMu.Lock()
+		delete(matchers, "even")
+		matchersMu.Unlock()
+	}()
+
+	assert.Empty(t, Compare("$even", 2.0, CompareParams{}))
+	assert.Len(t, Compare("$even", 3.0, CompareParams{}), 1)
+	assert.Empty(t, Compare(map[string]interface{}{"n": "$even"}, map[string]interface{}{"n": 4.0}, CompareParams{}))
+
+	// the registered matcher replaces the one with the same name
+	RegisterMatcher("even", func(args string, actual interface{}) (string, error) {
+		return "", nil
+	})
+	assert.Empty(t, Compare("$even", 3.0, CompareParams{}))
+
+	// the built-in matcher can be replaced too
+	builtinAny, _, _ := findMatcher("$any")
+	RegisterMatcher("any", func(args string, actual interface{}) (string, error) {
+		return "nothing matches", nil
+	})
+	defer RegisterMatcher("any", builtinAny)
+	assert.Len(t, Compare("$any", "value", CompareParams{}), 1)
+}
+
+func TestUnknownMatcherIsLiteral(t *testing.T) {
+	// the value which looks like the matcher, but isn't registered, is compared as the string
+	assert.Empty(t, Compare("$unknown(1)", "$unknown(1)", CompareParams{}))
+	assert.Empty(t, Compare("$price", "$price", CompareParams{}))
+	errs := Compare("$unknown", "value", CompareParams{})
+	require.Len(t, errs, 1)
+	assert.Equal(t, "values do not match", errs[0].(*mismatchError).msg)
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		args     string
+		expected []string
+	}{
+		{args: "", expected: nil},
+		{args: "a", expected: []string{"a"}},
+		{args: " a , b ", expected: []string{"a", "b"}},
+		{args: "a,", expected: []string{"a", ""}},
+		{args: `"a, b", c`, expected: []string{`"a, b"`, "c"}},
+		{args: `"a \", b", c`, expected: []string{`"a \", b"`, "c"}},
+		{args: `[1, 2], {"a": 1, "b": 2}`, expected: []string{"[1, 2]", `{"a": 1, "b": 2}`}},
+		{args: `f(a, b), [[1, 2], 3]`, expected: []string{"f(a, b)", "[[1, 2], 3]"}},
+		{args: `"[", b`, expected: []string{`"["`, "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.args, func(t *testing.T) {
+			assert.Equal(t, tt.expected, splitArgs(tt.args))
+		})
+	}
+}
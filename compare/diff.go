@@ -0,0 +1,357 @@
+package compare
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+const (
+	// diffContext is the number of unchanged lines around changes in the diff
+	diffContext = 3
+	// maxDiffCells limits the size of the table used to find changes, the diff is not made for larger values
+	maxDiffCells = 4000000
+)
+
+// DiffError reports mismatches of values with their diff
+type DiffError struct {
+	Title  string
+	Diff   string
+	Errors []error
+}
+
+// NewDiffError returns the error which reports the comparison errors with the diff of the values,
+// it returns nil if there are no errors
+func NewDiffError(title string, expected, actual interface{}, params CompareParams, errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return &DiffError{
+		Title:  title,
+		Diff:   Diff(expected, actual, params),
+		Errors: errs,
+	}
+}
+
+func (e *DiffError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(e.Title)
+	if e.Diff != "" {
+		sb.WriteString(":\n")
+		sb.WriteString(e.Diff)
+	}
+	for _, err := range e.Errors {
+		sb.WriteString("\n")
+		// mismatches are shown by the diff, so only their paths are listed
+		if m, ok := err.(*mismatchError); ok && e.Diff != "" {
+			sb.WriteString(m.short())
+		} else {
+			sb.WriteString(err.Error())
+		}
+	}
+	return sb.String()
+}
+
+// Diff returns the unified diff of the values, they are printed as JSON.
+// The expected value is shown as it's compared: values which match matchers are replaced
+// by actual values and annotated by the matchers, fields which are not checked are omitted.
+// Strings are compared line by line. Empty string is returned if values are equal.
+func Diff(expected, actual interface{}, params CompareParams) string {
+	var expectedLines, actualLines []diffLine
+	expectedStr, ok1 := expected.(string)
+	actualStr, ok2 := actual.(string)
+	if ok1 && ok2 && !isMatcherExpr(expectedStr) {
+		expectedLines, actualLines = textLines(expectedStr), textLines(actualStr)
+	} else {
+		params.Captures = nil
+		expected, actual = project(expected, actual, &params)
+		expectedLines, actualLines = printValue(expected), printValue(actual)
+	}
+	return unifiedDiff(expectedLines, actualLines)
+}
+
+// annotated is the expected value replaced by the actual one which matches the matcher
+type annotated struct {
+	value interface{}
+	note  string
+}
+
+func isMatcherExpr(expr string) bool {
+	if captureExprRx.MatchString(expr) {
+		return true
+	}
+	_, _, ok := findMatcher(expr)
+	return ok
+}
+
+// project makes the values look the same where they match according to the params
+func project(expected, actual interface{}, params *CompareParams) (interface{}, interface{}) {
+	if expr, ok := expected.(string); ok && isMatcherExpr(expr) {
+		if len(compareBranch("$", expected, actual, params)) == 0 {
+			return annotated{value: actual, note: expr}, actual
+		}
+		return expected, actual
+	}
+
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			return expected, actual
+		}
+		projectedExpected := make(map[string]interface{}, len(e))
+		projectedActual := make(map[string]interface{}, len(a))
+		for key, expectedValue := range e {
+			actualValue, ok := a[key]
+			if !ok {
+				projectedExpected[key] = expectedValue
+				continue
+			}
+			projectedExpected[key], projectedActual[key] = project(expectedValue, actualValue, params)
+		}
+		if params.DisallowExtraFields {
+			for key, actualValue := range a {
+				if _, ok := e[key]; !ok {
+					projectedActual[key] = actualValue
+				}
+			}
+		}
+		return projectedExpected, projectedActual
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok {
+			return expected, actual
+		}
+		if params.IgnoreArraysOrdering {
+			e = sortArray(e).([]interface{})
+			a = sortArray(a).([]interface{})
+		}
+		projectedExpected := append([]interface{}{}, e...)
+		projectedActual := append([]interface{}{}, a...)
+		for i := 0; i < len(e) && i < len(a); i++ {
+			projectedExpected[i], projectedActual[i] = project(e[i], a[i], params)
+		}
+		return projectedExpected, projectedActual
+	}
+
+	if params.IgnoreValues && getType(expected) == getType(actual) {
+		return actual, actual
+	}
+	return expected, actual
+}
+
+type diffLine struct {
+	text string
+	note string // annotation of the expected value
+}
+
+func textLines(s string) []diffLine {
+	var lines []diffLine
+	for _, l := range strings.Split(s, "\n") {
+		lines = append(lines, diffLine{text: l})
+	}
+	return lines
+}
+
+// printValue prints the value as indented JSON with sorted keys
+func printValue(value interface{}) []diffLine {
+	var lines []diffLine
+	printNode(&lines, "", "", value, "")
+	return lines
+}
+
+func printNode(lines *[]diffLine, indent, prefix string, value interface{}, suffix string) {
+	note := ""
+	if a, ok := value.(annotated); ok {
+		value, note = a.value, a.note
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			*lines = append(*lines, diffLine{text: indent + prefix + "{}" + suffix, note: note})
+			return
+		}
+		*lines = append(*lines, diffLine{text: indent + prefix + "{", note: note})
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for i, key := range keys {
+			printNode(lines, indent+"  ", printScalar(key)+": ", v[key], separator(i, len(keys)))
+		}
+		*lines = append(*lines, diffLine{text: indent + "}" + suffix})
+	case []interface{}:
+		if len(v) == 0 {
+			*lines = append(*lines, diffLine{text: indent + prefix + "[]" + suffix, note: note})
+			return
+		}
+		*lines = append(*lines, diffLine{text: indent + prefix + "[", note: note})
+		for i, item := range v {
+			printNode(lines, indent+"  ", "", item, separator(i, len(v)))
+		}
+		*lines = append(*lines, diffLine{text: indent + "]" + suffix})
+	default:
+		*lines = append(*lines, diffLine{text: indent + prefix + printScalar(value) + suffix, note: note})
+	}
+}
+
+func separator(i, n int) string {
+	if i < n-1 {
+		return ","
+	}
+	return ""
+}
+
+func printScalar(value interface{}) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err == nil {
+		return strings.TrimSuffix(buf.String(), "\n")
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+type diffOp struct {
+	kind     byte // ' ', '-' or '+'
+	line     diffLine
+	from, to int // numbers of the line in expected and actual values
+}
+
+// unifiedDiff finds the longest common subsequence of lines and prints changes in the unified format
+func unifiedDiff(expected, actual []diffLine) string {
+	// common prefix and suffix don't need the table
+	prefix := 0
+	for prefix < len(expected) && prefix < len(actual) && expected[prefix].text == actual[prefix].text {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(expected)-prefix && suffix < len(actual)-prefix &&
+		expected[len(expected)-1-suffix].text == actual[len(actual)-1-suffix].text {
+		suffix++
+	}
+	if prefix == len(expected) && prefix == len(actual) {
+		return ""
+	}
+
+	e := expected[prefix : len(expected)-suffix]
+	a := actual[prefix : len(actual)-suffix]
+	if (len(e)+1)*(len(a)+1) > maxDiffCells {
+		return ""
+	}
+
+	// lcs[i][j] is the length of the common subsequence of e[i:] and a[j:]
+	lcs := make([][]int32, len(e)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(a)+1)
+	}
+	for i := len(e) - 1; i >= 0; i-- {
+		for j := len(a) - 1; j >= 0; j-- {
+			if e[i].text == a[j].text {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(expected)+len(actual))
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, diffOp{kind: ' ', line: expected[i], from: i, to: i})
+	}
+	i, j := 0, 0
+	for i < len(e) || j < len(a) {
+		switch {
+		case i < len(e) && j < len(a) && e[i].text == a[j].text:
+			ops = append(ops, diffOp{kind: ' ', line: e[i], from: prefix + i, to: prefix + j})
+			i++
+			j++
+		case j == len(a) || (i < len(e) && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{kind: '-', line: e[i], from: prefix + i, to: prefix + j})
+			i++
+		default:
+			ops = append(ops, diffOp{kind: '+', line: a[j], from: prefix + i, to: prefix + j})
+			j++
+		}
+	}
+	for k := 0; k < suffix; k++ {
+		ops = append(ops, diffOp{kind: ' ', line: expected[prefix+len(e)+k], from: prefix + len(e) + k, to: prefix + len(a) + k})
+	}
+
+	var sb strings.Builder
+	sb.WriteString(color.GreenString("--- expected") + "\n" + color.RedString("+++ actual") + "\n")
+	for start := 0; start < len(ops); {
+		// find the next change and the end of its hunk
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		last := first
+		for k := first; k < len(ops); k++ {
+			if ops[k].kind != ' ' {
+				last = k
+			} else if k-last > 2*diffContext {
+				break
+			}
+		}
+		// the hunk begins after the previous one and ends with the last operation
+		from := first - diffContext
+		if from < start {
+			from = start
+		}
+		to := last + diffContext + 1
+		if to > len(ops) {
+			to = len(ops)
+		}
+		writeHunk(&sb, ops[from:to])
+		start = to
+	}
+	return sb.String()
+}
+
+func writeHunk(sb *strings.Builder, ops []diffOp) {
+	var expectedCount, actualCount int
+	for _, op := range ops {
+		if op.kind != '+' {
+			expectedCount++
+		}
+		if op.kind != '-' {
+			actualCount++
+		}
+	}
+	sb.WriteString(color.CyanString("@@ -%s +%s @@", hunkRange(ops[0].from, expectedCount), hunkRange(ops[0].to, actualCount)) + "\n")
+
+	for _, op := range ops {
+		text := string(op.kind) + " " + op.line.text
+		switch op.kind {
+		case '-':
+			text = color.GreenString("%s", text)
+		case '+':
+			text = color.RedString("%s", text)
+		}
+		if op.line.note != "" && op.kind != '+' {
+			text += color.CyanString("  // %s", op.line.note)
+		}
+		sb.WriteString(text + "\n")
+	}
+}
+
+// hunkRange prints the first line and the number of lines of the hunk,
+// the empty range starts at the line before it as in GNU diff
+func hunkRange(line, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", line)
+	}
+	return fmt.Sprintf("%d,%d", line+1, count)
+}
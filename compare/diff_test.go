@@ -0,0 +1,287 @@
+package compare
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+)
+
+// withoutColor disables colors of the diff for the test
+func withoutColor(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	t.Cleanup(func() { color.NoColor = noColor })
+}
+
+// numbered returns lines "prefix1", "prefix2", ... "prefixN"
+func numbered(prefix string, n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("%s%d", prefix, i+1)
+	}
+	return lines
+}
+
+func toDiffLines(texts []string) []diffLine {
+	lines := make([]diffLine, len(texts))
+	for i, text := range texts {
+		lines[i] = diffLine{text: text}
+	}
+	return lines
+}
+
+// replaced returns the copy of lines with the lines at the indexes replaced by "changed"
+func replaced(lines []string, indexes ...int) []string {
+	res := append([]string{}, lines...)
+	for _, i := range indexes {
+		res[i] = "changed"
+	}
+	return res
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	withoutColor(t)
+	ten := numbered("line", 10)
+
+	tests := []struct {
+		name         string
+		expected     []string
+		actual       []string
+		expectedDiff string
+	}{
+		{
+			name:     "equal",
+			expected: ten,
+			actual:   ten,
+		},
+		{
+			name:     "change in the middle is surrounded by the context",
+			expected: ten,
+			actual:   replaced(ten, 4),
+			expectedDiff: `--- expected
++++ actual
+@@ -2,7 +2,7 @@
+  line2
+  line3
+  line4
+- line5
++ changed
+  line6
+  line7
+  line8
+`,
+		},
+		{
+			name:     "context is cut by the beginning and the end",
+			expected: ten,
+			actual:   replaced(ten, 0, 9),
+			expectedDiff: `--- expected
++++ actual
+@@ -1,4 +1,4 @@
+- line1
++ changed
+  line2
+  line3
+  line4
+@@ -7,4 +7,4 @@
+  line7
+  line8
+  line9
+- line10
++ changed
+`,
+		},
+		{
+			name:     "changes separated by twice the context are merged",
+			expected: ten,
+			actual:   replaced(ten, 1, 8),
+			expectedDiff: `--- expected
++++ actual
+@@ -1,10 +1,10 @@
+  line1
+- line2
++ changed
+  line3
+  line4
+  line5
+  line6
+  line7
+  line8
+- line9
++ changed
+  line10
+`,
+		},
+		{
+			name:     "changes separated by more than twice the context are split",
+			expected: numbered("line", 11),
+			actual:   replaced(numbered("line", 11), 1, 9),
+			expectedDiff: `--- expected
++++ actual
+@@ -1,5 +1,5 @@
+  line1
+- line2
++ changed
+  line3
+  line4
+  line5
+@@ -7,5 +7,5 @@
+  line7
+  line8
+  line9
+- line10
++ changed
+  line11
+`,
+		},
+		{
+			name:     "lines are inserted and deleted",
+			expected: []string{"a", "b", "c", "d"},
+			actual:   []string{"a", "c", "d", "e", "f"},
+			expectedDiff: `--- expected
++++ actual
+@@ -1,4 +1,5 @@
+  a
+- b
+  c
+  d
++ e
++ f
+`,
+		},
+		{
+			name:     "actual is empty",
+			expected: []string{"a", "b"},
+			expectedDiff: `--- expected
++++ actual
+@@ -1,2 +0,0 @@
+- a
+- b
+`,
+		},
+		{
+			name:     "lines are appended",
+			expected: []string{"a"},
+			actual:   []string{"a", "b"},
+			expectedDiff: `--- expected
++++ actual
+@@ -1,1 +1,2 @@
+  a
++ b
+`,
+		},
+		{
+			name:     "common prefix and suffix are not limited by the table",
+			expected: append(append(numbered("prefix", 3000), "a"), numbered("suffix", 3000)...),
+			actual:   append(append(numbered("prefix", 3000), "b"), numbered("suffix", 3000)...),
+			expectedDiff: `--- expected
++++ actual
+@@ -2998,7 +2998,7 @@
+  prefix2998
+  prefix2999
+  prefix3000
+- a
++ b
+  suffix1
+  suffix2
+  suffix3
+`,
+		},
+		{
+			name:     "diff of too many changed lines is not made",
+			expected: append(numbered("expected", 2000), "same"),
+			actual:   append(numbered("actual", 2000), "same"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedDiff, unifiedDiff(toDiffLines(tt.expected), toDiffLines(tt.actual)))
+		})
+	}
+}
+
+func TestUnifiedDiffNotes(t *testing.T) {
+	withoutColor(t)
+	expected := []diffLine{{text: "{"}, {text: `  "id": 42,`, note: "$matchType(number)"}, {text: `  "name": "a"`}, {text: "}"}}
+	actual := toDiffLines([]string{"{", `  "id": 42,`, `  "name": "b"`, "}"})
+
+	assert.Equal(t, `--- expected
++++ actual
+@@ -1,4 +1,4 @@
+  {
+    "id": 42,  // $matchType(number)
+-   "name": "a"
++   "name": "b"
+  }
+`, unifiedDiff(expected, actual))
+}
+
+func TestDiff(t *testing.T) {
+	withoutColor(t)
+
+	tests := []struct {
+		name         string
+		expected     interface{}
+		actual       interface{}
+		params       CompareParams
+		expectedDiff string
+	}{
+		{
+			name:     "matched values are shown as actual ones",
+			expected: decode(t, `{"id": "$matchType(number)", "name": "a", "skipped": 1}`),
+			actual:   decode(t, `{"id": 42, "name": "b", "extra": true}`),
+			expectedDiff: `--- expected
++++ actual
+@@ -1,5 +1,4 @@
+  {
+    "id": 42,  // $matchType(number)
+-   "name": "a",
+-   "skipped": 1
++   "name": "b"
+  }
+`,
+		},
+		{
+			name:     "extra fields are shown if they are disallowed",
+			expected: decode(t, `{"id": 1}`),
+			actual:   decode(t, `{"id": 1, "extra": true}`),
+			params:   CompareParams{DisallowExtraFields: true},
+			expectedDiff: `--- expected
++++ actual
+@@ -1,3 +1,4 @@
+  {
++   "extra": true,
+    "id": 1
+  }
+`,
+		},
+		{
+			name:     "strings are compared by lines",
+			expected: "first\nsecond",
+			actual:   "first\nthird",
+			expectedDiff: `--- expected
++++ actual
+@@ -1,2 +1,2 @@
+  first
+- second
++ third
+`,
+		},
+		{
+			name:     "equal values",
+			expected: decode(t, `[1, 2]`),
+			actual:   decode(t, `[1, 2]`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := Diff(tt.expected, tt.actual, tt.params)
+			assert.Equal(t, tt.expectedDiff, diff, "diff:\n%s", strings.TrimSpace(diff))
+		})
+	}
+}
@@ -0,0 +1,361 @@
+package compare
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MatcherFunc checks the actual value by the arguments of the matcher expression $name(args),
+// it returns the description of the mismatch or an empty string if the value matches.
+// The error is returned if the arguments are invalid.
+type MatcherFunc func(args string, actual interface{}) (mismatch string, err error)
+
+var (
+	matchersMu sync.RWMutex
+	matchers   map[string]MatcherFunc
+)
+
+// the built-in matchers are registered in init because some of them use Compare
+func init() {
+	matchers = map[string]MatcherFunc{
+		"matchRegexp":   matchRegexp,
+		"any":           matchAny,
+		"notEmpty":      matchNotEmpty,
+		"matchType":     matchType,
+		"matchDate":     matchDate,
+		"approx":        matchApprox,
+		"oneOf":         matchOneOf,
+		"arrayContains": matchArrayContains,
+		"len":           matchLen,
+	}
+}
+
+// RegisterMatcher makes the matcher available in expected values as $name or $name(args),
+// the matcher registered with the same name is replaced
+func RegisterMatcher(name string, matcher MatcherFunc) {
+	matchersMu.Lock()
+	defer matchersMu.Unlock()
+	matchers[name] = matcher
+}
+
+var matcherExprRx = regexp.MustCompile(`(?s)^\$([A-Za-z_][A-Za-z0-9_]*)(?:\((.*)\))?$`)
+
+// findMatcher returns the registered matcher of the expression and its arguments
+func findMatcher(expr string) (MatcherFunc, string, bool) {
+	matches := matcherExprRx.FindStringSubmatch(expr)
+	if matches == nil {
+		return nil, "", false
+	}
+	matchersMu.RLock()
+	defer matchersMu.RUnlock()
+	matcher, ok := matchers[matches[1]]
+	return matcher, matches[2], ok
+}
+
+func match(path string, matcher MatcherFunc, args string, expected, actual interface{}) []error {
+	mismatch, err := matcher(args, actual)
+	if err != nil {
+		return []error{makeError(path, fmt.Sprintf("invalid matcher: %s", err), expected, actual)}
+	}
+	if mismatch != "" {
+		return []error{makeError(path, mismatch, expected, actual)}
+	}
+	return nil
+}
+
+func matchRegexp(args string, actual interface{}) (string, error) {
+	rx, err := regexp.Compile(args)
+	if err != nil {
+		return "", fmt.Errorf("can not compile regex: %s", err)
+	}
+	value, ok := actual.(string)
+	if !ok {
+		return "types do not match", nil
+	}
+	if !rx.MatchString(value) {
+		return "value does not match regex", nil
+	}
+	return "", nil
+}
+
+func matchAny(args string, actual interface{}) (string, error) {
+	return "", nil
+}
+
+func matchNotEmpty(args string, actual interface{}) (string, error) {
+	if actual == nil {
+		return "value is empty", nil
+	}
+	if length, ok := lengthOf(actual); ok && length == 0 {
+		return "value is empty", nil
+	}
+	return "", nil
+}
+
+var uuidRx = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+func matchType(args string, actual interface{}) (string, error) {
+	var ok bool
+	switch strings.TrimSpace(args) {
+	case "string":
+		_, ok = actual.(string)
+	case "number":
+		_, ok = toNumber(actual)
+	case "integer":
+		var n float64
+		n, ok = toNumber(actual)
+		ok = ok && n == math.Trunc(n)
+	case "boolean":
+		_, ok = actual.(bool)
+	case "null":
+		ok = actual == nil
+	case "array":
+		ok = getType(actual) == "array"
+	case "object":
+		ok = getType(actual) == "map"
+	case "uuid":
+		s, isString := actual.(string)
+		ok = isString && uuidRx.MatchString(s)
+	case "datetime":
+		s, isString := actual.(string)
+		if isString {
+			_, err := time.Parse(time.RFC3339, s)
+			ok = err == nil
+		}
+	default:
+		return "", fmt.Errorf("unknown type %s", args)
+	}
+	if !ok {
+		return "types do not match", nil
+	}
+	return "", nil
+}
+
+var dateLayouts = map[string]string{
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC822":      time.RFC822,
+	"RFC822Z":     time.RFC822Z,
+	"DateTime":    "2006-01-02 15:04:05",
+	"Date":        "2006-01-02",
+}
+
+// matchDate parses the value by the layout and checks it's within the range from now if it's set:
+// $matchDate(layout) or $matchDate(layout, ±5m), +5m and -5m allow dates in the future or in the past only
+func matchDate(args string, actual interface{}) (string, error) {
+	layout := strings.TrimSpace(args)
+	var rangeExpr string
+	// the layout may contain commas, so the range is the last argument
+	if i := strings.LastIndex(args, ","); i >= 0 {
+		if _, _, err := parseDateRange(args[i+1:]); err == nil {
+			layout, rangeExpr = strings.TrimSpace(args[:i]), args[i+1:]
+		}
+	}
+	if layout == "" {
+		return "", errors.New("layout is required")
+	}
+	if named, ok := dateLayouts[layout]; ok {
+		layout = named
+	}
+
+	value, ok := actual.(string)
+	if !ok {
+		return "types do not match", nil
+	}
+	date, err := time.Parse(layout, value)
+	if err != nil {
+		return "value does not match date layout", nil
+	}
+
+	if rangeExpr != "" {
+		before, after, _ := parseDateRange(rangeExpr)
+		now := time.Now()
+		if date.Before(now.Add(-before)) || date.After(now.Add(after)) {
+			return "date is out of range", nil
+		}
+	}
+	return "", nil
+}
+
+// parseDateRange returns the allowed distances before and after now
+func parseDateRange(expr string) (before, after time.Duration, err error) {
+	expr = strings.TrimSpace(expr)
+	sign := ""
+	for _, s := range []string{"±", "+", "-"} {
+		if strings.HasPrefix(expr, s) {
+			sign, expr = s, strings.TrimPrefix(expr, s)
+			break
+		}
+	}
+	d, err := time.ParseDuration(expr)
+	if err != nil {
+		return 0, 0, err
+	}
+	switch sign {
+	case "+":
+		return 0, d, nil
+	case "-":
+		return d, 0, nil
+	default:
+		return d, d, nil
+	}
+}
+
+// matchApprox checks the number: $approx(value, tolerance)
+func matchApprox(args string, actual interface{}) (string, error) {
+	parts := splitArgs(args)
+	if len(parts) != 2 {
+		return "", errors.New("value and tolerance are required")
+	}
+	expected, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid value: %s", err)
+	}
+	tolerance, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid tolerance: %s", err)
+	}
+
+	value, ok := toNumber(actual)
+	if !ok {
+		return "types do not match", nil
+	}
+	if math.Abs(value-expected) > tolerance {
+		return "value is out of tolerance", nil
+	}
+	return "", nil
+}
+
+// matchOneOf checks the value equals any of the arguments, they are parsed as JSON or taken as strings
+func matchOneOf(args string, actual interface{}) (string, error) {
+	options := splitArgs(args)
+	if len(options) == 0 {
+		return "", errors.New("options are required")
+	}
+	for _, option := range options {
+		if len(Compare(parseArg(option), actual, CompareParams{})) == 0 {
+			return "", nil
+		}
+	}
+	return "value does not match any option", nil
+}
+
+// matchArrayContains checks the array contains all the arguments, they are parsed as JSON or taken as strings
+func matchArrayContains(args string, actual interface{}) (string, error) {
+	elements := splitArgs(args)
+	if len(elements) == 0 {
+		return "", errors.New("elements are required")
+	}
+	items, ok := actual.([]interface{})
+	if !ok {
+		return "types do not match", nil
+	}
+	for _, element := range elements {
+		expected := parseArg(element)
+		found := false
+		for _, item := range items {
+			if len(Compare(expected, item, CompareParams{})) == 0 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Sprintf("array does not contain %s", element), nil
+		}
+	}
+	return "", nil
+}
+
+// matchLen checks the length of the array, the string or the number of keys of the object
+func matchLen(args string, actual interface{}) (string, error) {
+	expected, err := strconv.Atoi(strings.TrimSpace(args))
+	if err != nil {
+		return "", fmt.Errorf("invalid length: %s", err)
+	}
+	length, ok := lengthOf(actual)
+	if !ok {
+		return "value has no length", nil
+	}
+	if length != expected {
+		return fmt.Sprintf("length is %d", length), nil
+	}
+	return "", nil
+}
+
+func lengthOf(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case string:
+		return len([]rune(v)), true
+	case []interface{}:
+		return len(v), true
+	case map[string]interface{}:
+		return len(v), true
+	default:
+		return 0, false
+	}
+}
+
+// toNumber returns the value of the number, strings containing numbers are not numbers
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// splitArgs splits arguments by commas which are not inside quotes or brackets
+func splitArgs(args string) []string {
+	var parts []string
+	var current strings.Builder
+	depth := 0
+	inQuotes := false
+	escaped := false
+	for _, r := range args {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && inQuotes:
+			escaped = true
+		case r == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+		case r == '[' || r == '{' || r == '(':
+			depth++
+		case r == ']' || r == '}' || r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(current.String()))
+			current.Reset()
+			continue
+		}
+		current.WriteRune(r)
+	}
+	if last := strings.TrimSpace(current.String()); last != "" || len(parts) > 0 {
+		parts = append(parts, last)
+	}
+	return parts
+}
+
+// parseArg decodes the argument as JSON, the argument which is not a valid JSON is a string
+func parseArg(arg string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(arg), &value); err != nil {
+		return arg
+	}
+	return value
+}
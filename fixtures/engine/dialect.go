@@ -0,0 +1,177 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Dialect adapts the loader to the SQL of the database
+type Dialect interface {
+	// QuoteLiteral escapes the string to be safely passed as a value in SQL query
+	QuoteLiteral(value string) string
+	// QuoteIdentifier quotes the name of the table or the column
+	QuoteIdentifier(name string) string
+	// TruncateTables deletes all rows of the tables before loading
+	TruncateTables(tx *Tx, tables []string) error
+	// InsertRows inserts rows into the table and returns values of the inserted rows in the same order,
+	// values are required only for rows with Returning set, nil is returned if they can't be read
+	InsertRows(tx *Tx, table string, rows []Row) ([]map[string]interface{}, error)
+	// ResetSequences makes sequences of the tables generate values after the values of loaded rows
+	ResetSequences(tx *Tx, tables []string) error
+	// Tables returns names of all tables of the database, they are truncated by IsolationTruncate
+	Tables(tx *Tx) ([]string, error)
+}
+
+// Snapshotter is implemented by dialects which support IsolationSnapshot
+type Snapshotter interface {
+	// TakeSnapshot saves rows of the tables
+	TakeSnapshot(tx *Tx, tables []string) error
+	// RestoreSnapshot replaces rows of the tables by the saved ones
+	RestoreSnapshot(tx *Tx, tables []string) error
+	// DropSnapshot removes the saved rows
+	DropSnapshot(tx *Tx) error
+}
+
+// Checksummer is implemented by dialects which check tables of reused fixtures themselves,
+// e.g. by the database without reading their rows
+type Checksummer interface {
+	// Checksum returns the value which changes whenever rows of the tables are inserted, updated or deleted,
+	// or their sequences are moved
+	Checksum(tx *Tx, tables []string) (string, error)
+}
+
+// Row is the row to insert
+type Row struct {
+	// Values are SQL expressions of the values by the names of columns, missing columns get default values
+	Values map[string]string
+	// Returning means that values of the inserted row are referenced by other rows
+	Returning bool
+}
+
+// Columns returns sorted names of the columns of the rows
+func Columns(rows []Row) []string {
+	var columns []string
+	presence := make(map[string]bool)
+	for _, row := range rows {
+		for name := range row.Values {
+			if !presence[name] {
+				presence[name] = true
+				columns = append(columns, name)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// MaxBatchRows limits the number of rows in the batch
+const MaxBatchRows = 500
+
+// Batches splits rows into batches which can be inserted by one query:
+// consecutive rows with the same columns are batched, rows with Returning
+// or without values are inserted alone
+func Batches(rows []Row) [][]Row {
+	var batches [][]Row
+	var batch []Row
+	var batchColumns string
+	for _, row := range rows {
+		if row.Returning || len(row.Values) == 0 {
+			if len(batch) > 0 {
+				batches = append(batches, batch)
+				batch = nil
+			}
+			batches = append(batches, []Row{row})
+			continue
+		}
+		columns := strings.Join(Columns([]Row{row}), "\n")
+		if len(batch) > 0 && (columns != batchColumns || len(batch) == MaxBatchRows) {
+			batches = append(batches, batch)
+			batch = nil
+		}
+		batch = append(batch, row)
+		batchColumns = columns
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// Tx runs queries of the dialect in the transaction of loading
+type Tx struct {
+	ctx   context.Context
+	tx    executor
+	debug bool
+}
+
+// executor is either the transaction or the DB which connection is already in the transaction
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (t *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	t.printQuery(query, args)
+	return t.tx.ExecContext(t.ctx, query, args...)
+}
+
+func (t *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	t.printQuery(query, args)
+	return t.tx.QueryContext(t.ctx, query, args...)
+}
+
+func (t *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	t.printQuery(query, args)
+	return t.tx.QueryRowContext(t.ctx, query, args...)
+}
+
+func (t *Tx) printQuery(query string, args []interface{}) {
+	if t.debug {
+		fmt.Println(append([]interface{}{"Issuing SQL:", query}, args...)...)
+	}
+}
+
+// ScanRow reads the current row of the result into the map by column names,
+// bytes are converted to strings
+func ScanRow(rows *sql.Rows) (map[string]interface{}, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]interface{}, len(columns))
+	for i, value := range values {
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+		res[columns[i]] = value
+	}
+	return res, nil
+}
+
+// ScanStrings reads the only column of all rows of the result
+func ScanStrings(rows *sql.Rows) ([]string, error) {
+	defer func() { _ = rows.Close() }()
+	var res []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,504 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Loader loads fixtures into the database, SQL of the database is made by the dialect
+type Loader struct {
+	db       *sql.DB
+	location string
+	debug    bool
+	dialect  Dialect
+
+	isolation Isolation
+	// tables of the snapshot, they are nil until the snapshot is taken
+	snapshotTables []string
+	// the only connection of the DB is in the transaction of the run
+	inTransaction bool
+
+	// parsed files of fixtures by their paths, they don't change during the run
+	parsed   map[string]*fixture
+	parsedMu sync.Mutex
+
+	// reuse enables skipping the load of the same fixtures if their tables are unchanged
+	reuse  bool
+	last   lastLoad
+	lastMu sync.Mutex
+}
+
+// lastLoad is the last loaded set of fixtures
+type lastLoad struct {
+	names       string
+	tables      []string
+	fingerprint string
+}
+
+var evalRx = regexp.MustCompile(`^\$eval\((.+)\)$`)
+
+type row map[string]interface{}
+
+type table []row
+
+type rowsDict map[string]row
+
+type fixture struct {
+	Version   string
+	Inherits  []string
+	Tables    yaml.MapSlice
+	Templates yaml.MapSlice
+}
+
+type loadedTable struct {
+	Name string
+	Rows table
+}
+
+type loadContext struct {
+	dbContext      context.Context
+	files          []string
+	tables         []loadedTable
+	refsDefinition rowsDict
+	refsInserted   rowsDict
+}
+
+func New(db *sql.DB, location string, debug bool, dialect Dialect) *Loader {
+	return &Loader{
+		db:       db,
+		location: location,
+		debug:    debug,
+		dialect:  dialect,
+	}
+}
+
+func (l *Loader) Load(names []string) error {
+	return l.LoadContext(context.Background(), names)
+}
+
+func (l *Loader) LoadContext(dbContext context.Context, names []string) error {
+	if l.reuse {
+		reused, err := l.reuseLast(dbContext, names)
+		if err != nil {
+			return err
+		}
+		if reused {
+			l.printDebug("Fixtures", strings.Join(names, ", "), "are unchanged, loading is skipped")
+			return nil
+		}
+	}
+
+	ctx := loadContext{
+		dbContext:      dbContext,
+		refsDefinition: make(rowsDict),
+		refsInserted:   make(rowsDict),
+	}
+	// gather data from files
+	start := time.Now()
+	for _, name := range names {
+		err := l.loadFile(name, &ctx)
+		if err != nil {
+			return fmt.Errorf("unable to load fixture %s: %s", name, err.Error())
+		}
+	}
+	l.printDebug("Fixtures parsed in", time.Since(start))
+	return l.loadTables(&ctx, names)
+}
+
+func (l *Loader) loadFile(name string, ctx *loadContext) error {
+	candidates := []string{
+		l.location + "/" + name,
+		l.location + "/" + name + ".yml",
+		l.location + "/" + name + ".yaml",
+	}
+	var err error
+	var file string
+	for _, candidate := range candidates {
+		if _, err = os.Stat(candidate); err == nil {
+			file = candidate
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	// skip previously loaded files
+	if inArray(file, ctx.files) {
+		return nil
+	}
+	l.printDebug("Loading", file)
+	loadedFixture, err := l.parseFile(file)
+	if err != nil {
+		return err
+	}
+	ctx.files = append(ctx.files, file)
+	return l.loadYml(loadedFixture, ctx)
+}
+
+// parseFile reads the file of the fixture once, parsed files are reused by next tests
+func (l *Loader) parseFile(file string) (*fixture, error) {
+	l.parsedMu.Lock()
+	defer l.parsedMu.Unlock()
+
+	if loadedFixture, ok := l.parsed[file]; ok {
+		return loadedFixture, nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	// read yml into struct
+	var loadedFixture fixture
+	if err := yaml.Unmarshal(data, &loadedFixture); err != nil {
+		return nil, err
+	}
+	if l.parsed == nil {
+		l.parsed = make(map[string]*fixture)
+	}
+	l.parsed[file] = &loadedFixture
+	return &loadedFixture, nil
+}
+
+// loadYml collects templates and rows of the parsed file, the parsed file isn't modified
+func (l *Loader) loadYml(loadedFixture *fixture, ctx *loadContext) error {
+	// load inherits
+	for _, inheritFile := range loadedFixture.Inherits {
+		if err := l.loadFile(inheritFile, ctx); err != nil {
+			return err
+		}
+	}
+
+	// loadedFixture.Templates
+	// yaml.MapSlice{
+	//    string => yaml.MapSlice{
+	//        string => interface{}
+	//    }
+	// }
+	for _, template := range loadedFixture.Templates {
+		name := template.Key.(string)
+		if _, ok := ctx.refsDefinition[name]; ok {
+			return fmt.Errorf("unable to load template %s: duplicating ref name", name)
+		}
+		fields := template.Value.(yaml.MapSlice)
+		row := make(row, len(fields))
+		for _, field := range fields {
+			key := field.Key.(string)
+			value, _ := field.Value.(interface{})
+			row[key] = value
+		}
+		if base, ok := row["$extend"]; ok {
+			base := base.(string)
+			baseRow, err := l.resolveReference(ctx.refsDefinition, base)
+			if err != nil {
+				return err
+			}
+			for k, v := range row {
+				baseRow[k] = v
+			}
+			row = baseRow
+		}
+		ctx.refsDefinition[name] = row
+		if l.debug {
+			rowJson, _ := json.Marshal(row)
+			fmt.Printf("Populating ref %s as %s from template\n", name, string(rowJson))
+		}
+	}
+
+	// loadedFixture.Tables
+	// yaml.MapSlice{
+	//    string => []interface{
+	//        yaml.MapSlice{
+	//            string => interface{}
+	//        }
+	//    }
+	// }
+	for _, sourceTable := range loadedFixture.Tables {
+		sourceRows, ok := sourceTable.Value.([]interface{})
+		if !ok {
+			return errors.New("expected array at root level")
+		}
+		rows := make(table, len(sourceRows))
+		for i := range sourceRows {
+			sourceFields := sourceRows[i].(yaml.MapSlice)
+			fields := make(row, len(sourceFields))
+			for j := range sourceFields {
+				fields[sourceFields[j].Key.(string)] = sourceFields[j].Value
+			}
+			rows[i] = fields
+		}
+		lt := loadedTable{
+			Name: sourceTable.Key.(string),
+			Rows: rows,
+		}
+		ctx.tables = append(ctx.tables, lt)
+	}
+	return nil
+}
+
+func (l *Loader) loadTables(ctx *loadContext, names []string) error {
+	return l.inTx(ctx.dbContext, func(tx *Tx) error {
+		tables, err := l.loadTablesTx(tx, ctx)
+		if err != nil {
+			return err
+		}
+		if l.reuse {
+			return l.remember(tx, names, tables)
+		}
+		return nil
+	})
+}
+
+// inTx runs the function in the transaction, which is committed if the function succeeds.
+// The transaction isn't started in IsolationSavepoint, since the test is already in the transaction.
+func (l *Loader) inTx(ctx context.Context, f func(tx *Tx) error) error {
+	if l.isolation == IsolationSavepoint {
+		return f(&Tx{ctx: ctx, tx: l.db, debug: l.debug})
+	}
+
+	sqlTx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = sqlTx.Rollback() }()
+	if err := f(&Tx{ctx: ctx, tx: sqlTx, debug: l.debug}); err != nil {
+		return err
+	}
+	return sqlTx.Commit()
+}
+
+// loadTablesTx truncates tables of the fixtures and inserts their rows, it returns names of the tables
+func (l *Loader) loadTablesTx(tx *Tx, ctx *loadContext) ([]string, error) {
+	// truncate first
+	var tables []string
+	truncatedTables := make(map[string]bool)
+	for _, lt := range ctx.tables {
+		if _, ok := truncatedTables[lt.Name]; ok {
+			// already truncated
+			continue
+		}
+		tables = append(tables, lt.Name)
+		truncatedTables[lt.Name] = true
+	}
+	start := time.Now()
+	if err := l.dialect.TruncateTables(tx, tables); err != nil {
+		return nil, err
+	}
+	l.printDebug("Tables truncated in", time.Since(start))
+	// then load data
+	for _, lt := range ctx.tables {
+		if len(lt.Rows) == 0 {
+			continue
+		}
+		start := time.Now()
+		if err := l.loadTable(tx, ctx, lt.Name, lt.Rows); err != nil {
+			return nil, fmt.Errorf("failed to load table '%s' because:\n%s", lt.Name, err)
+		}
+		l.printDebug("Table", lt.Name, "loaded in", time.Since(start))
+	}
+	// alter the sequences so they continue after the loaded rows
+	start = time.Now()
+	if err := l.dialect.ResetSequences(tx, tables); err != nil {
+		return nil, err
+	}
+	l.printDebug("Sequences reset in", time.Since(start))
+	return tables, nil
+}
+
+func (l *Loader) loadTable(tx *Tx, ctx *loadContext, t string, rows table) error {
+	// $extend keyword allows to import values from a named row
+	for i, row := range rows {
+		if base, ok := row["$extend"]; ok {
+			base := base.(string)
+			baseRow, err := l.resolveReference(ctx.refsDefinition, base)
+			if err != nil {
+				return err
+			}
+			for k, v := range row {
+				baseRow[k] = v
+			}
+			rows[i] = baseRow
+		}
+	}
+	// build SQL values
+	dbRows := make([]Row, len(rows))
+	for i, row := range rows {
+		values, err := l.buildValues(ctx, t, i, row)
+		if err != nil {
+			return err
+		}
+		_, named := row["$name"]
+		dbRows[i] = Row{Values: values, Returning: named}
+	}
+	// issuing query
+	insertedRows, err := l.dialect.InsertRows(tx, t, dbRows)
+	if err != nil {
+		return err
+	}
+	// reading results
+	for i, row := range rows {
+		name, ok := row["$name"]
+		if !ok {
+			continue
+		}
+		refName := name.(string)
+		if _, ok := ctx.refsDefinition[refName]; ok {
+			return fmt.Errorf("duplicating ref name %s", refName)
+		}
+		// add to references
+		ctx.refsDefinition[refName] = row
+		if l.debug {
+			rowJson, _ := json.Marshal(row)
+			fmt.Printf("Populating ref %s as %s from row definition\n", refName, string(rowJson))
+		}
+		// the dialect couldn't read the inserted row, so its fields can't be referenced
+		if i >= len(insertedRows) || insertedRows[i] == nil {
+			continue
+		}
+		ctx.refsInserted[refName] = insertedRows[i]
+		if l.debug {
+			valuesJson, _ := json.Marshal(insertedRows[i])
+			fmt.Printf("Populating ref %s as %s from inserted values\n", refName, string(valuesJson))
+		}
+	}
+	return nil
+}
+
+// buildValues converts values read from yaml to SQL expressions
+func (l *Loader) buildValues(ctx *loadContext, t string, i int, row row) (map[string]string, error) {
+	values := make(map[string]string, len(row))
+	for name, value := range row {
+		if len(name) > 0 && name[0] == '$' {
+			continue
+		}
+		// resolve references
+		if stringValue, ok := value.(string); ok {
+			if len(stringValue) > 0 && stringValue[0] == '$' {
+				dbValue, err := l.resolveExpression(stringValue, ctx)
+				if err != nil {
+					return nil, err
+				}
+				values[name] = dbValue
+				continue
+			}
+		}
+		dbValue, err := l.toDbValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("unable to process %s value (row %d of %s): %s", name, i, t, err.Error())
+		}
+		values[name] = dbValue
+	}
+	return values, nil
+}
+
+// resolveExpression converts expressions starting with dollar sign into a value
+// supporting expressions:
+// - $eval()               - executes an SQL expression, e.g. $eval(CURRENT_DATE)
+// - $recordName.fieldName - using value of previously inserted named record
+func (l *Loader) resolveExpression(expr string, ctx *loadContext) (string, error) {
+	if strings.HasPrefix(expr, "$eval") {
+		if matches := evalRx.FindStringSubmatch(expr); matches != nil {
+			return "(" + matches[1] + ")", nil
+		}
+		return "", fmt.Errorf("incorrect $eval() usage: %s", expr)
+	}
+	value, err := l.resolveFieldReference(ctx.refsInserted, expr)
+	if err != nil {
+		return "", err
+	}
+	return l.toDbValue(value)
+}
+
+// resolveReference finds previously stored reference by its name
+func (l *Loader) resolveReference(refs rowsDict, refName string) (row, error) {
+	target, ok := refs[refName]
+	if !ok {
+		return nil, fmt.Errorf("undefined reference %s", refName)
+	}
+	// make a copy of referencing data to prevent spoiling the source
+	// by the way removing $-records from base row
+	targetCopy := make(row, len(target))
+	for k, v := range target {
+		if len(k) == 0 || k[0] != '$' {
+			targetCopy[k] = v
+		}
+	}
+	return targetCopy, nil
+}
+
+// resolveFieldReference finds previously stored reference by name
+// and return value of its field
+func (l *Loader) resolveFieldReference(refs rowsDict, ref string) (interface{}, error) {
+	parts := strings.SplitN(ref, ".", 2)
+	if len(parts) < 2 || len(parts[0]) < 2 || len(parts[1]) < 1 {
+		return nil, fmt.Errorf("invalid reference %s, correct form is $refName.field", ref)
+	}
+	// remove leading $
+	refName := parts[0][1:]
+	target, ok := refs[refName]
+	if !ok {
+		return nil, fmt.Errorf("undefined reference %s", refName)
+	}
+	value, ok := target[parts[1]]
+	if !ok {
+		return nil, fmt.Errorf("undefined reference field %s", parts[1])
+	}
+	return value, nil
+}
+
+// toDbValue prepares value to be passed in SQL query
+// with respect to its type and converts it to string
+func (l *Loader) toDbValue(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		return l.dialect.QuoteLiteral(value), nil
+	case int:
+		return strconv.Itoa(value), nil
+	case int64:
+		return strconv.FormatInt(value, 10), nil
+	case float64:
+		return strconv.FormatFloat(value, 'g', -1, 64), nil
+	case json.Number:
+		return value.String(), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case time.Time:
+		return l.dialect.QuoteLiteral(value.Format("2006-01-02 15:04:05.999999999")), nil
+	}
+	// the value is either slice or map, so insert it as JSON string
+	// fixme: marshaller doesn't know how to encode map[interface{}]interface{}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return l.dialect.QuoteLiteral(string(encoded)), nil
+}
+
+func (l *Loader) printDebug(a ...interface{}) {
+	if l.debug {
+		fmt.Println(a...)
+	}
+}
+
+// inArray checks whether the needle is present in haystack slice
+func inArray(needle string, haystack []string) bool {
+	for _, e := range haystack {
+		if needle == e {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,361 @@
+package engine_test
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+
+	"github.com/rezikovka/gonkey/fixtures/engine"
+	"github.com/rezikovka/gonkey/fixtures/sqlite"
+)
+
+const schema = `
+PRAGMA foreign_keys = ON;
+CREATE TABLE posts (id INTEGER PRIMARY KEY AUTOINCREMENT, title TEXT NOT NULL DEFAULT 'Untitled', views INTEGER, meta TEXT);
+CREATE TABLE comments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	post_id INTEGER NOT NULL REFERENCES posts (id),
+	author TEXT,
+	content TEXT
+);
+`
+
+// newLoader makes the loader of the fixtures files into the in-memory SQLite database
+func newLoader(t *testing.T, files map[string]string) (*engine.Loader, *sql.DB) {
+	dir, err := ioutil.TempDir("", "gonkey-engine")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	for name, content := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	db, err := sql.Open("sqlite", "file::memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	// the in-memory database exists only in its connection
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(schema)
+	require.NoError(t, err)
+
+	return engine.New(db, dir, false, sqlite.Dialect{}), db
+}
+
+func queryStrings(t *testing.T, db *sql.DB, query string) []string {
+	rows, err := db.Query(query)
+	require.NoError(t, err)
+	var res []string
+	for rows.Next() {
+		var s string
+		require.NoError(t, rows.Scan(&s))
+		res = append(res, s)
+	}
+	require.NoError(t, rows.Err())
+	require.NoError(t, rows.Close())
+	return res
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name             string
+		files            map[string]string
+		load             []string
+		expectedPosts    []string
+		expectedComments []string
+	}{
+		{
+			name: "references to inserted rows",
+			files: map[string]string{"posts.yml": `
+tables:
+  posts:
+    - $name: first
+      title: First
+    - title: Second
+  comments:
+    - post_id: $first.id
+      content: $first.title
+`},
+			load:             []string{"posts"},
+			expectedPosts:    []string{"1|First||", "2|Second||"},
+			expectedComments: []string{"1|First"},
+		},
+		{
+			name: "tables of inherited fixtures are loaded first",
+			files: map[string]string{
+				"base.yaml": `
+tables:
+  posts:
+    - $name: base
+      title: Base
+`,
+				"child.yml": `
+inherits:
+  - base
+tables:
+  comments:
+    - post_id: $base.id
+      content: To the base
+  posts:
+    - title: Child
+`,
+			},
+			load:             []string{"child"},
+			expectedPosts:    []string{"1|Base||", "2|Child||"},
+			expectedComments: []string{"1|To the base"},
+		},
+		{
+			name: "fixtures are loaded once",
+			files: map[string]string{
+				"base.yml":  "tables:\n  posts:\n    - title: Base\n",
+				"child.yml": "inherits:\n  - base\ntables:\n  posts:\n    - title: Child\n",
+			},
+			load:          []string{"base", "child", "base.yml"},
+			expectedPosts: []string{"1|Base||", "2|Child||"},
+		},
+		{
+			name: "templates",
+			files: map[string]string{"posts.yml": `
+templates:
+  post:
+    title: Template
+    views: 10
+  popular:
+    $extend: post
+    views: 1000
+tables:
+  posts:
+    - $extend: post
+    - $extend: popular
+      title: Popular
+`},
+			load:          []string{"posts"},
+			expectedPosts: []string{"1|Template|10|", "2|Popular|1000|"},
+		},
+		{
+			name: "rows extend named rows of loaded tables",
+			files: map[string]string{
+				"base.yml": `
+tables:
+  posts:
+    - $name: first
+      title: First
+      views: 5
+`,
+				"posts.yml": `
+inherits:
+  - base
+tables:
+  posts:
+    - $extend: first
+      title: Second
+`,
+			},
+			load:          []string{"posts"},
+			expectedPosts: []string{"1|First|5|", "2|Second|5|"},
+		},
+		{
+			name: "eval",
+			files: map[string]string{"posts.yml": `
+tables:
+  posts:
+    - title: $eval('Eval' || 'uated')
+      views: $eval(2 * 21)
+`},
+			load:          []string{"posts"},
+			expectedPosts: []string{"1|Evaluated|42|"},
+		},
+		{
+			name: "values of types",
+			files: map[string]string{"posts.yml": `
+tables:
+  posts:
+    - title: It's quoted
+      views: null
+      meta: [a, b]
+`},
+			load:          []string{"posts"},
+			expectedPosts: []string{`1|It's quoted||["a","b"]`},
+		},
+		{
+			name: "rows without values",
+			files: map[string]string{"posts.yml": `
+tables:
+  posts:
+    - title: First
+    - {}
+    - title: Third
+`},
+			load:          []string{"posts"},
+			expectedPosts: []string{"1|First||", "2|Untitled||", "3|Third||"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader, db := newLoader(t, tt.files)
+			// rows of the previous load are replaced
+			for i := 0; i < 2; i++ {
+				require.NoError(t, loader.Load(tt.load))
+			}
+
+			assert.Equal(t, tt.expectedPosts, queryStrings(t, db,
+				"SELECT id || '|' || title || '|' || COALESCE(views, '') || '|' || COALESCE(meta, '') FROM posts ORDER BY id"))
+			assert.Equal(t, tt.expectedComments, queryStrings(t, db,
+				"SELECT post_id || '|' || COALESCE(content, '') FROM comments ORDER BY id"))
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		fixture       string
+		expectedError string
+	}{
+		{
+			name:          "undefined reference",
+			fixture:       "tables:\n  comments:\n    - post_id: $post.id",
+			expectedError: "failed to load table 'comments' because:\nundefined reference post",
+		},
+		{
+			name:          "invalid reference",
+			fixture:       "tables:\n  comments:\n    - post_id: $post",
+			expectedError: "failed to load table 'comments' because:\ninvalid reference $post, correct form is $refName.field",
+		},
+		{
+			name:          "undefined field of reference",
+			fixture:       "tables:\n  posts:\n    - $name: post\n      title: Post\n  comments:\n    - post_id: $post.uuid",
+			expectedError: "failed to load table 'comments' because:\nundefined reference field uuid",
+		},
+		{
+			name:          "incorrect eval",
+			fixture:       "tables:\n  posts:\n    - title: $eval(",
+			expectedError: "failed to load table 'posts' because:\nincorrect $eval() usage: $eval(",
+		},
+		{
+			name:          "duplicating ref name",
+			fixture:       "tables:\n  posts:\n    - $name: post\n      title: First\n    - $name: post\n      title: Second",
+			expectedError: "failed to load table 'posts' because:\nduplicating ref name post",
+		},
+		{
+			name:          "duplicating template name",
+			fixture:       "templates:\n  post:\n    title: First\ntables:\n  posts:\n    - $name: post\n      title: Second",
+			expectedError: "failed to load table 'posts' because:\nduplicating ref name post",
+		},
+		{
+			name:          "extending undefined template",
+			fixture:       "templates:\n  post:\n    $extend: base",
+			expectedError: "unable to load fixture posts: undefined reference base",
+		},
+		{
+			name:          "table is not an array",
+			fixture:       "tables:\n  posts:\n    title: First",
+			expectedError: "unable to load fixture posts: expected array at root level",
+		},
+		{
+			name:          "failed insert",
+			fixture:       "tables:\n  posts:\n    - name: First",
+			expectedError: "table posts has no column named name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader, db := newLoader(t, map[string]string{"posts.yml": tt.fixture})
+			_, err := db.Exec("INSERT INTO posts (title) VALUES ('Kept')")
+			require.NoError(t, err)
+
+			err = loader.Load([]string{"posts"})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedError)
+			// the failed load is rolled back
+			assert.Equal(t, []string{"Kept"}, queryStrings(t, db, "SELECT title FROM posts"))
+		})
+	}
+
+	loader, _ := newLoader(t, nil)
+	assert.Error(t, loader.Load([]string{"missing"}))
+}
+
+func TestBatches(t *testing.T) {
+	row := func(columns ...string) engine.Row {
+		values := make(map[string]string)
+		for _, c := range columns {
+			values[c] = "1"
+		}
+		return engine.Row{Values: values}
+	}
+	returning := row("id")
+	returning.Returning = true
+
+	many := make([]engine.Row, engine.MaxBatchRows+1)
+	for i := range many {
+		many[i] = row("id")
+	}
+
+	tests := []struct {
+		name          string
+		rows          []engine.Row
+		expectedSizes []int
+	}{
+		{
+			name:          "rows with the same columns",
+			rows:          []engine.Row{row("id", "title"), row("title", "id"), row("id", "title")},
+			expectedSizes: []int{3},
+		},
+		{
+			name:          "columns change",
+			rows:          []engine.Row{row("id"), row("id"), row("id", "title"), row("id")},
+			expectedSizes: []int{2, 1, 1},
+		},
+		{
+			name:          "returning rows are alone",
+			rows:          []engine.Row{row("id"), returning, row("id"), row("id")},
+			expectedSizes: []int{1, 1, 2},
+		},
+		{
+			name:          "rows without values are alone",
+			rows:          []engine.Row{row(), row(), row("id")},
+			expectedSizes: []int{1, 1, 1},
+		},
+		{
+			name:          "batch is limited",
+			rows:          many,
+			expectedSizes: []int{engine.MaxBatchRows, 1},
+		},
+		{
+			name: "no rows",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sizes []int
+			var batched []engine.Row
+			for _, batch := range engine.Batches(tt.rows) {
+				sizes = append(sizes, len(batch))
+				batched = append(batched, batch...)
+			}
+			assert.Equal(t, tt.expectedSizes, sizes)
+			// the order of rows is kept
+			assert.Equal(t, len(tt.rows), len(batched))
+			for i := range batched {
+				assert.Equal(t, tt.rows[i], batched[i])
+			}
+		})
+	}
+}
+
+func TestColumns(t *testing.T) {
+	rows := []engine.Row{
+		{Values: map[string]string{"title": "'a'", "id": "1"}},
+		{Values: map[string]string{"views": "2", "id": "2"}},
+	}
+	assert.Equal(t, []string{"id", "title", "views"}, engine.Columns(rows))
+	assert.Empty(t, engine.Columns(nil))
+}
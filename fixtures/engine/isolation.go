@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Isolation is the strategy of isolating tests from changes made to the database by other tests
+type Isolation int
+
+const (
+	// IsolationFixtures truncates only tables of fixtures of the test, it's the default
+	IsolationFixtures Isolation = iota
+	// IsolationTruncate truncates all tables of the database before each test
+	IsolationTruncate
+	// IsolationSnapshot saves all tables before the first test and restores them before each next test
+	IsolationSnapshot
+	// IsolationSavepoint runs the tests in the transaction and rolls back changes of each test to the savepoint,
+	// the tested service must use the same DB limited to one connection, so its queries run in the transaction
+	IsolationSavepoint
+)
+
+// savepointName is the name of the savepoint made before each test
+const savepointName = "gonkey_test"
+
+// SetIsolation sets the strategy of isolation, it must be called before the first test
+func (l *Loader) SetIsolation(isolation Isolation) error {
+	switch isolation {
+	case IsolationSnapshot:
+		if _, ok := l.dialect.(Snapshotter); !ok {
+			return errors.New("snapshot isolation is not supported by the database")
+		}
+	case IsolationSavepoint:
+		if l.db.Stats().MaxOpenConnections != 1 {
+			return errors.New("savepoint isolation requires the DB limited to one connection by SetMaxOpenConns(1)")
+		}
+	}
+	l.isolation = isolation
+	return nil
+}
+
+// Isolation returns the strategy of isolation
+func (l *Loader) Isolation() Isolation {
+	return l.isolation
+}
+
+// BeginTest prepares the database for the test, it's called before each test even if the test has no fixtures
+func (l *Loader) BeginTest(ctx context.Context) error {
+	switch l.isolation {
+	case IsolationTruncate:
+		return l.inTx(ctx, func(tx *Tx) error {
+			tables, err := l.dialect.Tables(tx)
+			if err != nil {
+				return err
+			}
+			if len(tables) == 0 {
+				return nil
+			}
+			if err := l.dialect.TruncateTables(tx, tables); err != nil {
+				return err
+			}
+			return l.dialect.ResetSequences(tx, tables)
+		})
+	case IsolationSnapshot:
+		snapshotter := l.dialect.(Snapshotter)
+		return l.inTx(ctx, func(tx *Tx) error {
+			if l.snapshotTables != nil {
+				return snapshotter.RestoreSnapshot(tx, l.snapshotTables)
+			}
+			tables, err := l.dialect.Tables(tx)
+			if err != nil {
+				return err
+			}
+			if err := snapshotter.TakeSnapshot(tx, tables); err != nil {
+				return fmt.Errorf("unable to take snapshot: %s", err)
+			}
+			l.snapshotTables = append([]string{}, tables...)
+			return nil
+		})
+	case IsolationSavepoint:
+		tx := &Tx{ctx: ctx, tx: l.db, debug: l.debug}
+		if !l.inTransaction {
+			if _, err := tx.Exec("BEGIN"); err != nil {
+				return err
+			}
+			l.inTransaction = true
+		}
+		_, err := tx.Exec("SAVEPOINT " + savepointName)
+		return err
+	}
+	return nil
+}
+
+// EndTest discards changes of the test in IsolationSavepoint, it's called after each test.
+// The error is returned if the service has ended the transaction, e.g. by COMMIT, so changes
+// of the test are not discarded, or if the failed query has aborted the transaction in PostgreSQL,
+// so queries made after it have been ignored.
+func (l *Loader) EndTest() error {
+	if l.isolation != IsolationSavepoint || !l.inTransaction {
+		return nil
+	}
+	tx := &Tx{ctx: context.Background(), tx: l.db, debug: l.debug}
+	// the aborted transaction fails any query until it's rolled back to the savepoint
+	_, abortedErr := tx.Exec("SELECT 1")
+	if _, err := tx.Exec("ROLLBACK TO SAVEPOINT " + savepointName); err != nil {
+		// the transaction is started again by the next test,
+		// the transaction started by the service after COMMIT is rolled back
+		l.inTransaction = false
+		_, _ = tx.Exec("ROLLBACK")
+		return fmt.Errorf("the transaction has been ended during the test, e.g. by COMMIT of the service, changes of the test are not discarded: %s", err)
+	}
+	if abortedErr != nil {
+		return fmt.Errorf("the transaction has been aborted by the failed query during the test, next queries of the test have been ignored: %s", abortedErr)
+	}
+	return nil
+}
+
+// Close ends the isolation after all tests: the transaction is rolled back in IsolationSavepoint,
+// the snapshot is dropped in IsolationSnapshot
+func (l *Loader) Close() error {
+	switch l.isolation {
+	case IsolationSnapshot:
+		if l.snapshotTables == nil {
+			return nil
+		}
+		l.snapshotTables = nil
+		return l.inTx(context.Background(), l.dialect.(Snapshotter).DropSnapshot)
+	case IsolationSavepoint:
+		if !l.inTransaction {
+			return nil
+		}
+		l.inTransaction = false
+		tx := &Tx{ctx: context.Background(), tx: l.db, debug: l.debug}
+		_, err := tx.Exec("ROLLBACK")
+		return err
+	}
+	return nil
+}
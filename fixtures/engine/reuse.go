@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SetReuse enables skipping the load of fixtures if the test declares the same fixtures
+// as the last loaded ones and rows of their tables haven't been changed since the load
+func (l *Loader) SetReuse(reuse bool) {
+	l.reuse = reuse
+}
+
+// reuseLast tells whether the fixtures are the last loaded ones and their tables contain the loaded rows.
+// Nothing is written to reused tables, the fingerprint includes sequences, so moved sequences cause the load.
+func (l *Loader) reuseLast(ctx context.Context, names []string) (bool, error) {
+	l.lastMu.Lock()
+	last := l.last
+	l.lastMu.Unlock()
+
+	if last.names == "" || last.names != strings.Join(names, "\n") {
+		return false, nil
+	}
+
+	start := time.Now()
+	var fingerprint string
+	err := l.inTx(ctx, func(tx *Tx) error {
+		var err error
+		fingerprint, err = l.fingerprint(tx, last.tables)
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("unable to check fixtures tables: %s", err)
+	}
+	l.printDebug("Fixtures tables checked in", time.Since(start))
+	return fingerprint == last.fingerprint, nil
+}
+
+// remember saves the fingerprint of the loaded tables
+func (l *Loader) remember(tx *Tx, names, tables []string) error {
+	fingerprint, err := l.fingerprint(tx, tables)
+	if err != nil {
+		return fmt.Errorf("unable to check fixtures tables: %s", err)
+	}
+
+	l.lastMu.Lock()
+	defer l.lastMu.Unlock()
+	l.last = lastLoad{
+		names:       strings.Join(names, "\n"),
+		tables:      tables,
+		fingerprint: fingerprint,
+	}
+	return nil
+}
+
+// fingerprint returns the checksum of the tables computed by the database,
+// rows of the tables are read and hashed if the dialect can't compute it, sequences aren't checked then
+func (l *Loader) fingerprint(tx *Tx, tables []string) (string, error) {
+	if checksummer, ok := l.dialect.(Checksummer); ok {
+		return checksummer.Checksum(tx, tables)
+	}
+
+	queries := make([]string, len(tables))
+	for i, t := range tables {
+		queries[i] = "SELECT * FROM " + l.dialect.QuoteIdentifier(t)
+	}
+	return HashRows(tx, queries)
+}
+
+// HashRows returns the hash of rows selected by the queries, the order of rows of each query doesn't matter
+func HashRows(tx *Tx, queries []string) (string, error) {
+	hash := sha256.New()
+	for _, query := range queries {
+		rows, err := tx.Query(query)
+		if err != nil {
+			return "", err
+		}
+		var printed []string
+		for rows.Next() {
+			row, err := ScanRow(rows)
+			if err != nil {
+				_ = rows.Close()
+				return "", err
+			}
+			// keys of maps are printed in sorted order
+			printed = append(printed, fmt.Sprintf("%#v", row))
+		}
+		_ = rows.Close()
+		if err := rows.Err(); err != nil {
+			return "", err
+		}
+		sort.Strings(printed)
+
+		fmt.Fprintf(hash, "%s\n%d\n", query, len(printed))
+		for _, p := range printed {
+			fmt.Fprintln(hash, p)
+		}
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
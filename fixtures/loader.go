@@ -1,13 +1,20 @@
 package fixtures
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"strings"
 
+	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
 
+	"github.com/rezikovka/gonkey/fixtures/engine"
 	"github.com/rezikovka/gonkey/fixtures/mysql"
 	"github.com/rezikovka/gonkey/fixtures/postgres"
+	"github.com/rezikovka/gonkey/fixtures/sqlite"
 )
 
 type DbType int
@@ -16,25 +23,77 @@ const (
 	_ = iota
 	Postgres
 	Mysql
+	Sqlite
 )
 
 const (
 	PostgresParam = "postgres"
 	MysqlParam    = "mysql"
+	SqliteParam   = "sqlite"
+)
+
+// Isolation is the strategy of isolating tests from changes made to the database by other tests
+type Isolation = engine.Isolation
+
+const (
+	IsolationFixtures  = engine.IsolationFixtures
+	IsolationTruncate  = engine.IsolationTruncate
+	IsolationSnapshot  = engine.IsolationSnapshot
+	IsolationSavepoint = engine.IsolationSavepoint
+)
+
+const (
+	IsolationFixturesParam  = "fixtures"
+	IsolationTruncateParam  = "truncate"
+	IsolationSnapshotParam  = "snapshot"
+	IsolationSavepointParam = "savepoint"
 )
 
 type Config struct {
-	DB       *sql.DB
-	DbType   DbType
-	Location string
-	Debug    bool
+	DB        *sql.DB
+	DbType    DbType
+	Location  string
+	Debug     bool
+	Isolation Isolation
+	// Reuse skips loading of the same fixtures as the last loaded ones if rows of their tables are unchanged
+	Reuse bool
 }
 
 type Loader interface {
 	Load(names []string) error
 }
 
+// ContextLoader is a Loader which can be cancelled
+type ContextLoader interface {
+	Loader
+	LoadContext(ctx context.Context, names []string) error
+}
+
+// IsolatedLoader is a Loader which isolates tests by the strategy,
+// its tests can't run concurrently unless the strategy is IsolationFixtures
+type IsolatedLoader interface {
+	ContextLoader
+	Isolation() Isolation
+	// BeginTest prepares the database before each test, even if the test has no fixtures
+	BeginTest(ctx context.Context) error
+	// EndTest is called after each test
+	EndTest() error
+	// Close is called after all tests
+	Close() error
+}
+
+// engineLoader is implemented by loaders of the fixtures engine
+type engineLoader interface {
+	SetIsolation(isolation Isolation) error
+	SetReuse(reuse bool)
+}
+
+// NewLoader makes the loader of the database, it panics if the config is invalid,
+// so the config given by the user must be checked by ValidateConfig first
 func NewLoader(cfg *Config) Loader {
+	if err := ValidateConfig(cfg); err != nil {
+		panic(err)
+	}
 
 	var loader Loader
 
@@ -53,20 +112,99 @@ func NewLoader(cfg *Config) Loader {
 			location,
 			cfg.Debug,
 		)
+	case Sqlite:
+		loader = sqlite.New(
+			cfg.DB,
+			location,
+			cfg.Debug,
+		)
 	default:
 		panic("unknown db type")
 	}
 
+	if cfg.Isolation != IsolationFixtures {
+		if err := loader.(engineLoader).SetIsolation(cfg.Isolation); err != nil {
+			panic(err)
+		}
+	}
+	loader.(engineLoader).SetReuse(cfg.Reuse)
+
 	return loader
 }
 
+// ValidateConfig returns the error if the loader can't be made by the config
+func ValidateConfig(cfg *Config) error {
+	switch cfg.DbType {
+	case Postgres, Mysql, Sqlite:
+	default:
+		return errors.New("unknown db type")
+	}
+	switch cfg.Isolation {
+	case IsolationFixtures, IsolationTruncate, IsolationSnapshot, IsolationSavepoint:
+	default:
+		return fmt.Errorf("unknown db isolation %d", cfg.Isolation)
+	}
+	if !SupportsIsolation(cfg.DbType, cfg.Isolation) {
+		return errors.New("db isolation is not supported by the db type")
+	}
+	if cfg.Isolation == IsolationSavepoint && (cfg.DB == nil || cfg.DB.Stats().MaxOpenConnections != 1) {
+		return errors.New("savepoint db isolation requires the DB limited to one connection by SetMaxOpenConns(1)")
+	}
+	return nil
+}
+
 func FetchDbType(dbType string) DbType {
 	switch dbType {
 	case PostgresParam:
 		return Postgres
 	case MysqlParam:
 		return Mysql
+	case SqliteParam:
+		return Sqlite
 	default:
 		panic("unknown db type param")
 	}
 }
+
+// FetchIsolation returns the isolation strategy by its name
+func FetchIsolation(isolation string) (Isolation, error) {
+	switch isolation {
+	case IsolationFixturesParam, "":
+		return IsolationFixtures, nil
+	case IsolationTruncateParam:
+		return IsolationTruncate, nil
+	case IsolationSnapshotParam:
+		return IsolationSnapshot, nil
+	case IsolationSavepointParam:
+		return IsolationSavepoint, nil
+	default:
+		return 0, fmt.Errorf("unknown db isolation %s", isolation)
+	}
+}
+
+// SupportsIsolation tells whether the isolation strategy can be used with the db type
+func SupportsIsolation(dbType DbType, isolation Isolation) bool {
+	switch isolation {
+	case IsolationSnapshot:
+		return dbType == Postgres
+	case IsolationSavepoint:
+		// TRUNCATE commits the transaction in MySQL
+		return dbType != Mysql
+	default:
+		return true
+	}
+}
+
+// DriverName returns the name of the database/sql driver of the db type
+func DriverName(dbType DbType) string {
+	switch dbType {
+	case Postgres:
+		return "postgres"
+	case Mysql:
+		return "mysql"
+	case Sqlite:
+		return "sqlite"
+	default:
+		panic("unknown db type")
+	}
+}
@@ -0,0 +1,185 @@
+package fixtures
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rezikovka/gonkey/fixtures/mysql"
+	"github.com/rezikovka/gonkey/fixtures/postgres"
+	"github.com/rezikovka/gonkey/fixtures/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE posts (id INTEGER PRIMARY KEY AUTOINCREMENT, title TEXT NOT NULL);
+CREATE TABLE comments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	post_id INTEGER NOT NULL REFERENCES posts (id),
+	content TEXT
+);
+`
+
+const sqliteFixture = `
+tables:
+  posts:
+    - $name: post
+      title: First
+    - title: Second
+  comments:
+    - post_id: $post.id
+      content: A comment
+`
+
+// TestSqliteLoader checks that the SQLite driver is registered by the package,
+// it must pass without cgo, since gonkey is built with CGO_ENABLED=0
+func TestSqliteLoader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gonkey-fixtures")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "posts.yml"), []byte(sqliteFixture), 0644))
+
+	db, err := sql.Open(DriverName(Sqlite), "file:"+filepath.Join(dir, "test.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(sqliteSchema)
+	require.NoError(t, err)
+
+	loader := NewLoader(&Config{DB: db, DbType: Sqlite, Location: dir})
+	for i := 0; i < 2; i++ {
+		require.NoError(t, loader.Load([]string{"posts"}))
+	}
+
+	var posts, postId int
+	var content string
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM posts").Scan(&posts))
+	require.NoError(t, db.QueryRow("SELECT post_id, content FROM comments").Scan(&postId, &content))
+	assert.Equal(t, 2, posts)
+	// AUTOINCREMENT counters are reset by the second load
+	assert.Equal(t, 1, postId)
+	assert.Equal(t, "A comment", content)
+}
+
+func TestSqliteReuse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gonkey-fixtures")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "posts.yml"), []byte(sqliteFixture), 0644))
+
+	db, err := sql.Open(DriverName(Sqlite), "file:"+filepath.Join(dir, "test.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(sqliteSchema)
+	require.NoError(t, err)
+
+	loader := NewLoader(&Config{DB: db, DbType: Sqlite, Location: dir, Reuse: true})
+	require.NoError(t, loader.Load([]string{"posts"}))
+
+	// the test inserts and deletes the row, rows are unchanged, but the counter is moved,
+	// so the fixtures are loaded again and the counter is reset
+	_, err = db.Exec("INSERT INTO posts (title) VALUES ('Third')")
+	require.NoError(t, err)
+	_, err = db.Exec("DELETE FROM posts WHERE title = 'Third'")
+	require.NoError(t, err)
+	require.NoError(t, loader.Load([]string{"posts"}))
+
+	res, err := db.Exec("INSERT INTO posts (title) VALUES ('Third')")
+	require.NoError(t, err)
+	id, err := res.LastInsertId()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), id)
+
+	// the test changes the row, so the fixtures are loaded again
+	_, err = db.Exec("UPDATE posts SET title = 'Changed' WHERE id = 1")
+	require.NoError(t, err)
+	require.NoError(t, loader.Load([]string{"posts"}))
+
+	var title string
+	var posts int
+	require.NoError(t, db.QueryRow("SELECT title FROM posts WHERE id = 1").Scan(&title))
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM posts").Scan(&posts))
+	assert.Equal(t, "First", title)
+	assert.Equal(t, 2, posts)
+}
+
+func TestSqliteSavepointEndedByService(t *testing.T) {
+	db, err := sql.Open(DriverName(Sqlite), "file::memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(sqliteSchema)
+	require.NoError(t, err)
+
+	loader := NewLoader(&Config{DB: db, DbType: Sqlite, Isolation: IsolationSavepoint}).(IsolatedLoader)
+	ctx := context.Background()
+	countPosts := func() (n int) {
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM posts").Scan(&n))
+		return n
+	}
+
+	// the test commits the transaction
+	require.NoError(t, loader.BeginTest(ctx))
+	_, err = db.Exec("INSERT INTO posts (title) VALUES ('committed')")
+	require.NoError(t, err)
+	_, err = db.Exec("COMMIT")
+	require.NoError(t, err)
+	err = loader.EndTest()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the transaction has been ended during the test")
+	assert.Equal(t, 1, countPosts())
+
+	// the isolation is restored for the next test
+	require.NoError(t, loader.BeginTest(ctx))
+	_, err = db.Exec("INSERT INTO posts (title) VALUES ('discarded')")
+	require.NoError(t, err)
+	require.NoError(t, loader.EndTest())
+	require.NoError(t, loader.Close())
+	assert.Equal(t, 1, countPosts())
+}
+
+func TestValidateConfig(t *testing.T) {
+	db, err := sql.Open(DriverName(Sqlite), "file::memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	assert.NoError(t, ValidateConfig(&Config{DB: db, DbType: Postgres, Isolation: IsolationSnapshot}))
+	assert.EqualError(t, ValidateConfig(&Config{DB: db}), "unknown db type")
+	assert.EqualError(t, ValidateConfig(&Config{DB: db, DbType: Mysql, Isolation: IsolationSnapshot}),
+		"db isolation is not supported by the db type")
+	assert.EqualError(t, ValidateConfig(&Config{DB: db, DbType: Sqlite, Isolation: IsolationSavepoint}),
+		"savepoint db isolation requires the DB limited to one connection by SetMaxOpenConns(1)")
+
+	db.SetMaxOpenConns(1)
+	assert.NoError(t, ValidateConfig(&Config{DB: db, DbType: Sqlite, Isolation: IsolationSavepoint}))
+}
+
+func TestNewLoaderDialect(t *testing.T) {
+	tests := []struct {
+		dbType         DbType
+		expectedLoader Loader
+	}{
+		{dbType: Postgres, expectedLoader: &postgres.LoaderPostgres{}},
+		{dbType: Mysql, expectedLoader: &mysql.LoaderMysql{}},
+		{dbType: Sqlite, expectedLoader: &sqlite.LoaderSqlite{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(DriverName(tt.dbType), func(t *testing.T) {
+			// the DB isn't connected until the first query
+			db, err := sql.Open(DriverName(tt.dbType), "")
+			require.NoError(t, err)
+			defer db.Close()
+
+			loader := NewLoader(&Config{DB: db, DbType: tt.dbType, Location: "fixtures/"})
+			assert.IsType(t, tt.expectedLoader, loader)
+			assert.Implements(t, (*IsolatedLoader)(nil), loader)
+		})
+	}
+
+	assert.Panics(t, func() { NewLoader(&Config{}) })
+}
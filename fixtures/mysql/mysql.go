@@ -2,362 +2,145 @@ package mysql
 
 import (
 	"database/sql"
-	"encoding/json"
-	"errors"
 	"fmt"
-	"io/ioutil"
-	"os"
 	"regexp"
-	"sort"
-	"strconv"
 	"strings"
 
-	"gopkg.in/yaml.v2"
+	"github.com/rezikovka/gonkey/fixtures/engine"
 )
 
+// LoaderMysql loads fixtures into MySQL
 type LoaderMysql struct {
-	db       *sql.DB
-	location string
-	debug    bool
+	*engine.Loader
 }
 
-const errNoIdColumn = "Error 1054: Unknown column 'id' in 'where clause'"
-
-type row map[string]interface{}
-
-type table []row
-
-type rowsDict map[string]row
-
-type fixture struct {
-	Version   string
-	Inherits  []string
-	Tables    yaml.MapSlice
-	Templates yaml.MapSlice
-}
+// Dialect makes SQL of MySQL for the fixtures engine
+type Dialect struct{}
 
-type loadedTable struct {
-	Name string
-	Rows table
-}
-
-type loadContext struct {
-	files          []string
-	tables         []loadedTable
-	refsDefinition rowsDict
-	refsInserted   rowsDict
-}
+const errNoIdColumn = "Error 1054: Unknown column 'id' in 'where clause'"
 
 func New(db *sql.DB, location string, debug bool) *LoaderMysql {
 	return &LoaderMysql{
-		db:       db,
-		location: location,
-		debug:    debug,
-	}
-}
-
-func (l *LoaderMysql) Load(names []string) error {
-	ctx := loadContext{
-		refsDefinition: make(rowsDict),
-		refsInserted:   make(rowsDict),
-	}
-
-	// gather data from files
-	for _, name := range names {
-		err := l.loadFile(name, &ctx)
-		if err != nil {
-			return fmt.Errorf("unable to load fixture %s: %s", name, err.Error())
-		}
+		Loader: engine.New(db, location, debug, Dialect{}),
 	}
-
-	return l.loadTables(&ctx)
 }
 
-func (l *LoaderMysql) loadFile(name string, ctx *loadContext) error {
-	candidates := []string{
-		l.location + "/" + name,
-		l.location + "/" + name + ".yml",
-		l.location + "/" + name + ".yaml",
-	}
-
-	var err error
-	var file string
-
-	for _, candidate := range candidates {
-		if _, err = os.Stat(candidate); err == nil {
-			file = candidate
-			break
-		}
-	}
-	if err != nil {
-		return err
-	}
-
-	// skip previously loaded files
-	if inArray(file, &(*ctx).files) {
-		return nil
-	}
-
-	l.printDebug("Loading", file)
-
-	data, err := ioutil.ReadFile(file)
-	if err != nil {
-		return err
-	}
-	(*ctx).files = append((*ctx).files, file)
-	return l.loadYml(data, ctx)
-}
-
-func (l *LoaderMysql) loadYml(data []byte, ctx *loadContext) error {
-	// read yml into struct
-	var loadedFixture fixture
-	if err := yaml.Unmarshal(data, &loadedFixture); err != nil {
+// TruncateTables truncates tables, it resets AUTO_INCREMENT counters too.
+// Foreign keys are not checked, since MySQL doesn't truncate tables referenced by others.
+func (Dialect) TruncateTables(tx *engine.Tx, tables []string) error {
+	if _, err := tx.Exec("SET FOREIGN_KEY_CHECKS = 0"); err != nil {
 		return err
 	}
-
-	// load inherits
-	for _, inheritFile := range loadedFixture.Inherits {
-		if err := l.loadFile(inheritFile, ctx); err != nil {
+	for _, name := range tables {
+		query := fmt.Sprintf("TRUNCATE TABLE %s", quoteIdentifier(name))
+		if _, err := tx.Exec(query); err != nil {
 			return err
 		}
 	}
+	_, err := tx.Exec("SET FOREIGN_KEY_CHECKS = 1")
+	return err
+}
 
-	for _, template := range loadedFixture.Templates {
-		name := template.Key.(string)
-		if _, ok := ctx.refsDefinition[name]; ok {
-			return fmt.Errorf("unable to load template %s: duplicating ref name", name)
-		}
-
-		fields := template.Value.(yaml.MapSlice)
-		row := make(row, len(fields))
-		for _, field := range fields {
-			key := field.Key.(string)
-			value, _ := field.Value.(interface{})
-			row[key] = value
+// InsertRows inserts batches of rows by one query, rows which values are returned
+// are inserted one by one, since MySQL can't return inserted rows
+func (Dialect) InsertRows(tx *engine.Tx, t string, rows []engine.Row) ([]map[string]interface{}, error) {
+	values := make([]map[string]interface{}, len(rows))
+	i := 0
+	for _, batch := range engine.Batches(rows) {
+		insertRes, err := tx.Exec(buildInsertQuery(t, batch))
+		if err != nil {
+			return nil, err
 		}
-
-		if base, ok := row["$extend"]; ok {
-			base := base.(string)
-			baseRow, err := l.resolveReference(ctx.refsDefinition, base)
+		if batch[0].Returning {
+			values[i], err = insertedRow(tx, insertRes, t)
 			if err != nil {
-				return err
-			}
-			for k, v := range row {
-				baseRow[k] = v
-			}
-			row = baseRow
-		}
-
-		ctx.refsDefinition[name] = row
-		if l.debug {
-			rowJson, _ := json.Marshal(row)
-			fmt.Printf("Populating ref %s as %s from template\n", name, string(rowJson))
-		}
-	}
-
-	for _, sourceTable := range loadedFixture.Tables {
-		sourceRows, ok := sourceTable.Value.([]interface{})
-		if !ok {
-			return errors.New("expected array at root level")
-		}
-		rows := make(table, len(sourceRows))
-		for i := range sourceRows {
-			sourceFields := sourceRows[i].(yaml.MapSlice)
-			fields := make(row, len(sourceFields))
-			for j := range sourceFields {
-				fields[sourceFields[j].Key.(string)] = sourceFields[j].Value
+				return nil, err
 			}
-			rows[i] = fields
-		}
-		lt := loadedTable{
-			Name: sourceTable.Key.(string),
-			Rows: rows,
-		}
-		(*ctx).tables = append((*ctx).tables, lt)
-	}
-	return nil
-}
-
-func (l *LoaderMysql) loadTables(ctx *loadContext) error {
-	tx, err := l.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer func() { _ = tx.Rollback() }()
-
-	// truncate first
-	truncatedTables := make(map[string]bool)
-	for _, lt := range ctx.tables {
-		if _, ok := truncatedTables[lt.Name]; ok {
-			// already truncated
-			continue
-		}
-		if err := l.truncateTable(tx, lt.Name); err != nil {
-			return err
-		}
-		truncatedTables[lt.Name] = true
-	}
-
-	// then load data
-	for _, lt := range ctx.tables {
-		if len(lt.Rows) == 0 {
-			continue
-		}
-		if err := l.loadTable(tx, ctx, lt.Name, lt.Rows); err != nil {
-			return err
 		}
+		i += len(batch)
 	}
-
-	return tx.Commit()
+	return values, nil
 }
 
-func (l *LoaderMysql) truncateTable(tx *sql.Tx, name string) error {
-	query := fmt.Sprintf("TRUNCATE TABLE `%s`", name)
-
-	l.printDebug("Issuing SQL:", query)
-
-	_, err := tx.Exec(query)
-	if err != nil {
-		return err
-	}
-
+// ResetSequences does nothing, AUTO_INCREMENT counters are reset by TRUNCATE and moved by inserted rows
+func (Dialect) ResetSequences(tx *engine.Tx, tables []string) error {
 	return nil
 }
 
-func (l *LoaderMysql) loadTable(tx *sql.Tx, ctx *loadContext, t string, rows table) error {
-
-	// $extend keyword allows to import values from a named row
-	for i, row := range rows {
-		if base, ok := row["$extend"]; ok {
-			base := base.(string)
-			baseRow, err := l.resolveReference(ctx.refsDefinition, base)
-			if err != nil {
-				return err
-			}
-			for k, v := range row {
-				baseRow[k] = v
-			}
-			rows[i] = baseRow
-		}
+// Checksum returns checksums of rows of the tables computed by the server and their AUTO_INCREMENT counters
+func (Dialect) Checksum(tx *engine.Tx, tables []string) (string, error) {
+	if len(tables) == 0 {
+		return "", nil
 	}
-
-	// issuing query
-	for _, row := range rows {
-		if err := l.loadRow(tx, ctx, t, row); err != nil {
-			return err
-		}
+	names := make([]string, len(tables))
+	for i, name := range tables {
+		names[i] = quoteIdentifier(name)
 	}
-
-	return nil
-}
-
-func (l *LoaderMysql) loadRow(tx *sql.Tx, ctx *loadContext, t string, row row) error {
-	query, err := l.buildInsertQuery(ctx, t, row)
+	rows, err := tx.Query("CHECKSUM TABLE " + strings.Join(names, ", "))
 	if err != nil {
-		return err
-	}
-	l.printDebug("Issuing SQL:", query)
-
-	insertRes, err := tx.Exec(query)
-	if err != nil {
-		return err
+		return "", err
 	}
+	defer func() { _ = rows.Close() }()
 
-	// find inserted rows
-	insertedRow, err := l.insertedRows(tx, insertRes, t)
-	defer func() {
-		if insertedRow != nil {
-			_ = insertedRow.Close()
+	var sums []string
+	for rows.Next() {
+		var name string
+		var sum sql.NullString
+		if err := rows.Scan(&name, &sum); err != nil {
+			return "", err
 		}
-	}()
-
-	if err != nil {
-		return err
+		sums = append(sums, name+"/"+sum.String)
 	}
-
-	// TODO: we couldn't get insertedRow because don't know Primary Key
-	if insertedRow == nil {
-		return nil
-	}
-
-	if !insertedRow.Next() {
-		return errors.New("can't get inserted row")
+	if err := rows.Err(); err != nil {
+		return "", err
 	}
 
-	if name, ok := row["$name"]; ok {
-		name := name.(string)
-		if _, ok := ctx.refsDefinition[name]; ok {
-			return fmt.Errorf("duplicating ref name %s", name)
-		}
-
-		insertedRowValue, err := fetchRow(insertedRow)
+	for _, name := range names {
+		counter, err := autoIncrement(tx, name)
 		if err != nil {
-			return err
-		}
-
-		// add to references
-		ctx.refsDefinition[name] = row
-		if l.debug {
-			rowJson, _ := json.Marshal(insertedRowValue)
-			fmt.Printf(
-				"Populating ref %s as %s from row definition\n",
-				name,
-				string(rowJson),
-			)
-		}
-
-		ctx.refsInserted[name] = insertedRowValue
-		if l.debug {
-			valuesJson, _ := json.Marshal(insertedRowValue)
-			fmt.Printf(
-				"Populating ref %s as %s from inserted values\n",
-				name,
-				string(valuesJson),
-			)
+			return "", err
 		}
+		sums = append(sums, name+"/"+counter)
 	}
-
-	return nil
+	return strings.Join(sums, ","), nil
 }
 
-func fetchRow(rows *sql.Rows) (row, error) {
-	res := make(row)
+// autoIncrementRe finds the AUTO_INCREMENT counter in the table definition
+var autoIncrementRe = regexp.MustCompile(`AUTO_INCREMENT=(\d+)`)
 
-	cols, err := rows.Columns()
-	if err != nil {
-		return nil, err
+// autoIncrement returns the AUTO_INCREMENT counter of the quoted table, it's empty if the counter hasn't been moved.
+// The counter is read from the definition of the table, since information_schema caches it in MySQL 8.
+func autoIncrement(tx *engine.Tx, quotedName string) (string, error) {
+	var name, definition string
+	if err := tx.QueryRow("SHOW CREATE TABLE "+quotedName).Scan(&name, &definition); err != nil {
+		return "", err
 	}
-
-	rawResult := make([]sql.RawBytes, len(cols))
-
-	dest := make([]interface{}, len(cols))
-	for i := range rawResult {
-		dest[i] = &rawResult[i]
+	if m := autoIncrementRe.FindStringSubmatch(definition); m != nil {
+		return m[1], nil
 	}
+	return "", nil
+}
 
-	// read values
-	if err := rows.Scan(dest...); err != nil {
+// Tables returns tables of the current database
+func (Dialect) Tables(tx *engine.Tx) ([]string, error) {
+	rows, err := tx.Query(
+		"SELECT table_name FROM information_schema.tables " +
+			"WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE' ORDER BY table_name",
+	)
+	if err != nil {
 		return nil, err
 	}
-
-	for i, raw := range rawResult {
-		if raw == nil {
-			res[cols[i]] = "NULL"
-		} else {
-			res[cols[i]] = string(raw)
-		}
-	}
-
-	return res, nil
+	return engine.ScanStrings(rows)
 }
 
-func (l *LoaderMysql) insertedRows(tx *sql.Tx, insertRes sql.Result, t string) (*sql.Rows, error) {
+// insertedRow finds the inserted row by its id, nil is returned if the table has no id column
+func insertedRow(tx *engine.Tx, insertRes sql.Result, t string) (map[string]interface{}, error) {
 	lastId, err := insertRes.LastInsertId()
 	if err != nil {
 		return nil, err
 	}
 
-	query := fmt.Sprintf("SELECT * FROM `%s` WHERE `id` = ?", t)
+	query := fmt.Sprintf("SELECT * FROM %s WHERE `id` = ?", quoteIdentifier(t))
 
 	rows, err := tx.Query(query, lastId)
 	if err != nil {
@@ -370,185 +153,54 @@ func (l *LoaderMysql) insertedRows(tx *sql.Tx, insertRes sql.Result, t string) (
 
 		return nil, err
 	}
+	defer func() { _ = rows.Close() }()
 
-	return rows, nil
-}
-
-// buildInsertQuery builds SQL query for data insertion
-// based on values read from yaml
-func (l *LoaderMysql) buildInsertQuery(ctx *loadContext, t string, row row) (string, error) {
-
-	var fields []string
-
-	for name := range row {
-		if strings.HasPrefix(name, "$") {
-			continue
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
 		}
-		fields = append(fields, name)
+		return nil, fmt.Errorf("can't get inserted row")
 	}
+	return engine.ScanRow(rows)
+}
 
-	sort.Strings(fields)
-
-	values := make([]string, len(fields))
-
-	for i, name := range fields {
-		val := row[name]
-
-		v, err := l.rowInsertValue(ctx, val)
-		if err != nil {
-			return "", fmt.Errorf(
-				"unable to process %s value (of %s): %s",
-				name, t, err.Error(),
-			)
+// buildInsertQuery builds SQL query for data insertion, rows of the batch have the same columns
+func buildInsertQuery(t string, rows []engine.Row) string {
+	fields := engine.Columns(rows)
+	values := make([]string, len(rows))
+	for i, row := range rows {
+		rowValues := make([]string, len(fields))
+		for k, name := range fields {
+			rowValues[k] = row.Values[name]
 		}
-
-		values[i] = v
+		values[i] = "(" + strings.Join(rowValues, ", ") + ")"
 	}
-
-	// quote fields
-	for i, field := range fields {
-		fields[i] = "`" + field + "`"
+	for i, name := range fields {
+		fields[i] = quoteIdentifier(name)
 	}
 
-	query := "INSERT INTO `%s` (%s) VALUES %s"
+	query := "INSERT INTO %s (%s) VALUES %s"
 	return fmt.Sprintf(
 		query,
-		t,
+		quoteIdentifier(t),
 		strings.Join(fields, ", "),
-		"("+strings.Join(values, ", ")+")",
-	), nil
+		strings.Join(values, ", "),
+	)
 }
 
-func (l *LoaderMysql) rowInsertValue(ctx *loadContext, val interface{}) (string, error) {
-
-	// resolve references
-	if stringValue, ok := val.(string); ok {
-		if strings.HasPrefix(stringValue, "$") {
-			v, err := l.resolveExpression(stringValue, ctx)
-			if err != nil {
-				return "", err
-			}
-			return v, nil
-		}
-	}
-
-	dbValue, err := toDbValue(val)
-	if err != nil {
-		return "", err
-	}
-	return dbValue, nil
-}
-
-// resolveExpression converts expressions starting with dollar sign into a value
-// supporting expressions:
-// - $eval()               - executes an SQL expression, e.g. $eval(CURRENT_DATE)
-// - $recordName.fieldName - using value of previously inserted named record
-func (l *LoaderMysql) resolveExpression(expr string, ctx *loadContext) (string, error) {
-	if expr[:5] == "$eval" {
-		re := regexp.MustCompile(`^\$eval\((.+)\)$`)
-		if matches := re.FindStringSubmatch(expr); matches != nil {
-			return "(" + matches[1] + ")", nil
-		} else {
-			return "", fmt.Errorf("icorrect $eval() usage: %s", expr)
-		}
-	} else {
-		value, err := l.resolveFieldReference(ctx.refsInserted, expr)
-		if err != nil {
-			return "", err
-		}
-		return toDbValue(value)
-	}
-}
-
-// resolveReference finds previously stored reference by its name
-func (l *LoaderMysql) resolveReference(refs rowsDict, refName string) (row, error) {
-	target, ok := refs[refName]
-	if !ok {
-		return nil, fmt.Errorf("undefined reference %s", refName)
-	}
-	// make a copy of referencing data to prevent spoiling the source
-	// by the way removing $-records from base row
-	targetCopy := make(row, len(target))
-	for k, v := range target {
-		if len(k) == 0 || k[0] != '$' {
-			targetCopy[k] = v
-		}
-	}
-	return targetCopy, nil
-}
-
-// resolveFieldReference finds previously stored reference by name
-// and return value of its field
-func (l *LoaderMysql) resolveFieldReference(refs rowsDict, ref string) (interface{}, error) {
-
-	parts := strings.SplitN(ref, ".", 2)
-	if len(parts) < 2 || len(parts[0]) < 2 || len(parts[1]) < 1 {
-		return nil, fmt.Errorf("invalid reference %s, correct form is $refName.field", ref)
-	}
-
-	// remove leading $
-	refName := parts[0][1:]
-
-	target, ok := refs[refName]
-	if !ok {
-		return nil, fmt.Errorf("undefined reference %s", refName)
-	}
-
-	value, ok := target[parts[1]]
-	if !ok {
-		return nil, fmt.Errorf("undefined reference field %s", parts[1])
-	}
-	return value, nil
-}
-
-// inArray checks whether the needle is present in haystack slice
-func inArray(needle string, haystack *[]string) bool {
-	for _, e := range *haystack {
-		if needle == e {
-			return true
-		}
-	}
-	return false
-}
-
-// toDbValue prepares value to be passed in SQL query
-// with respect to its type and converts it to string
-func toDbValue(value interface{}) (string, error) {
-
-	if value == nil {
-		return "NULL", nil
-	}
-	if value, ok := value.(string); ok {
-		return quoteLiteral(value), nil
-	}
-	if value, ok := value.(int); ok {
-		return strconv.Itoa(value), nil
-	}
-	if value, ok := value.(float64); ok {
-		return strconv.FormatFloat(value, 'g', -1, 64), nil
-	}
-	if value, ok := value.(bool); ok {
-		return strconv.FormatBool(value), nil
-	}
-	// the value is either slice or map, so insert it as JSON string
-	// fixme: marshaller doesn't know how to encode map[interface{}]interface{}
-	encoded, err := json.Marshal(value)
-	if err != nil {
-		return "", err
-	}
-	return quoteLiteral(string(encoded)), nil
-}
-
-// quoteLiteral properly escapes string to be safely
+// QuoteLiteral properly escapes string to be safely
 // passed as a value in SQL query
-func quoteLiteral(s string) string {
+func (Dialect) QuoteLiteral(s string) string {
 	s = strings.Replace(s, `'`, `''`, -1)
 	s = strings.Replace(s, `\`, `\\`, -1)
 	return "'" + s + "'"
 }
 
-func (l *LoaderMysql) printDebug(a ...interface{}) {
-	if l.debug {
-		fmt.Println(a...)
-	}
+// QuoteIdentifier quotes the name of the table or the column
+func (Dialect) QuoteIdentifier(s string) string {
+	return quoteIdentifier(s)
+}
+
+func quoteIdentifier(s string) string {
+	return "`" + strings.Replace(s, "`", "``", -1) + "`"
 }
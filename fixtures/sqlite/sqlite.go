@@ -0,0 +1,189 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/rezikovka/gonkey/fixtures/engine"
+)
+
+// LoaderSqlite loads fixtures into SQLite
+type LoaderSqlite struct {
+	*engine.Loader
+}
+
+// Dialect makes SQL of SQLite for the fixtures engine
+type Dialect struct{}
+
+// errNoRowid is the error of selecting inserted rows from the table created WITHOUT ROWID
+const errNoRowid = "no such column: rowid"
+
+func New(db *sql.DB, location string, debug bool) *LoaderSqlite {
+	return &LoaderSqlite{
+		Loader: engine.New(db, location, debug, Dialect{}),
+	}
+}
+
+// TruncateTables deletes all rows of the tables, SQLite has no TRUNCATE statement.
+// AUTOINCREMENT counters of the tables are reset too.
+func (Dialect) TruncateTables(tx *engine.Tx, tables []string) error {
+	// foreign keys are checked on commit, so tables can be truncated and loaded in any order
+	if _, err := tx.Exec("PRAGMA defer_foreign_keys = ON"); err != nil {
+		return err
+	}
+
+	for _, name := range tables {
+		if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s", quoteIdentifier(name))); err != nil {
+			return err
+		}
+	}
+	return resetAutoincrement(tx, tables)
+}
+
+// resetAutoincrement deletes AUTOINCREMENT counters of the tables,
+// so the tables continue after the max rowid of their rows
+func resetAutoincrement(tx *engine.Tx, tables []string) error {
+	sequences, err := hasSequences(tx)
+	if err != nil || !sequences {
+		return err
+	}
+	for _, name := range tables {
+		if _, err := tx.Exec("DELETE FROM sqlite_sequence WHERE name = ?", name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasSequences tells whether sqlite_sequence exists, it's created with the first table with AUTOINCREMENT column
+func hasSequences(tx *engine.Tx) (bool, error) {
+	var sequences int
+	err := tx.QueryRow(
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'sqlite_sequence'",
+	).Scan(&sequences)
+	return sequences > 0, err
+}
+
+// InsertRows inserts batches of rows by one query, rows which values are returned
+// are inserted one by one and found by last_insert_rowid()
+func (Dialect) InsertRows(tx *engine.Tx, t string, rows []engine.Row) ([]map[string]interface{}, error) {
+	values := make([]map[string]interface{}, len(rows))
+	i := 0
+	for _, batch := range engine.Batches(rows) {
+		insertRes, err := tx.Exec(buildInsertQuery(t, batch))
+		if err != nil {
+			return nil, err
+		}
+		if batch[0].Returning {
+			values[i], err = insertedRow(tx, insertRes, t)
+			if err != nil {
+				return nil, err
+			}
+		}
+		i += len(batch)
+	}
+	return values, nil
+}
+
+// ResetSequences does nothing, AUTOINCREMENT counters are reset by TruncateTables and moved by inserted rows
+func (Dialect) ResetSequences(tx *engine.Tx, tables []string) error {
+	return nil
+}
+
+// Checksum returns the hash of rows of the tables and their AUTOINCREMENT counters
+func (d Dialect) Checksum(tx *engine.Tx, tables []string) (string, error) {
+	var queries []string
+	literals := make([]string, len(tables))
+	for i, t := range tables {
+		queries = append(queries, "SELECT * FROM "+quoteIdentifier(t))
+		literals[i] = d.QuoteLiteral(t)
+	}
+	sequences, err := hasSequences(tx)
+	if err != nil {
+		return "", err
+	}
+	if sequences && len(tables) > 0 {
+		queries = append(queries, "SELECT name, seq FROM sqlite_sequence WHERE name IN ("+strings.Join(literals, ", ")+")")
+	}
+	return engine.HashRows(tx, queries)
+}
+
+// Tables returns tables of the main database except internal ones
+func (Dialect) Tables(tx *engine.Tx) ([]string, error) {
+	rows, err := tx.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	return engine.ScanStrings(rows)
+}
+
+// insertedRow finds the inserted row by its rowid, nil is returned if the table is WITHOUT ROWID
+func insertedRow(tx *engine.Tx, insertRes sql.Result, t string) (map[string]interface{}, error) {
+	lastId, err := insertRes.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf("SELECT * FROM %s WHERE rowid = ?", quoteIdentifier(t))
+
+	rows, err := tx.Query(query, lastId)
+	if err != nil {
+		if strings.Contains(err.Error(), errNoRowid) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("can't get inserted row")
+	}
+	return engine.ScanRow(rows)
+}
+
+// buildInsertQuery builds SQL query for data insertion, rows of the batch have the same columns
+func buildInsertQuery(t string, rows []engine.Row) string {
+	fields := engine.Columns(rows)
+	values := make([]string, len(rows))
+	for i, row := range rows {
+		rowValues := make([]string, len(fields))
+		for k, name := range fields {
+			rowValues[k] = row.Values[name]
+		}
+		values[i] = "(" + strings.Join(rowValues, ", ") + ")"
+	}
+	for i, name := range fields {
+		fields[i] = quoteIdentifier(name)
+	}
+	if len(fields) == 0 {
+		return fmt.Sprintf("INSERT INTO %s DEFAULT VALUES", quoteIdentifier(t))
+	}
+
+	query := "INSERT INTO %s (%s) VALUES %s"
+	return fmt.Sprintf(
+		query,
+		quoteIdentifier(t),
+		strings.Join(fields, ", "),
+		strings.Join(values, ", "),
+	)
+}
+
+// QuoteLiteral properly escapes string to be safely
+// passed as a value in SQL query
+func (Dialect) QuoteLiteral(s string) string {
+	s = strings.Replace(s, `'`, `''`, -1)
+	return "'" + s + "'"
+}
+
+// QuoteIdentifier quotes the name of the table or the column
+func (Dialect) QuoteIdentifier(s string) string {
+	return quoteIdentifier(s)
+}
+
+func quoteIdentifier(s string) string {
+	return `"` + strings.Replace(s, `"`, `""`, -1) + `"`
+}
@@ -0,0 +1,58 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/rezikovka/gonkey/fixtures/engine"
+)
+
+func TestBuildInsertQuery(t *testing.T) {
+	tests := []struct {
+		name          string
+		table         string
+		rows          []engine.Row
+		expectedQuery string
+	}{
+		{
+			name:          "one row",
+			table:         "posts",
+			rows:          []engine.Row{{Values: map[string]string{"title": "'First'", "id": "1"}}},
+			expectedQuery: `INSERT INTO "posts" ("id", "title") VALUES (1, 'First')`,
+		},
+		{
+			name:  "batch of rows",
+			table: "posts",
+			rows: []engine.Row{
+				{Values: map[string]string{"id": "1", "title": "'First'"}},
+				{Values: map[string]string{"id": "2", "title": "(CURRENT_DATE)"}},
+			},
+			expectedQuery: `INSERT INTO "posts" ("id", "title") VALUES (1, 'First'), (2, (CURRENT_DATE))`,
+		},
+		{
+			name:          "row without values",
+			table:         "posts",
+			rows:          []engine.Row{{Values: map[string]string{}}},
+			expectedQuery: `INSERT INTO "posts" DEFAULT VALUES`,
+		},
+		{
+			name:          "quoted names",
+			table:         `my "posts"`,
+			rows:          []engine.Row{{Values: map[string]string{"order": "1"}}},
+			expectedQuery: `INSERT INTO "my ""posts""" ("order") VALUES (1)`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedQuery, buildInsertQuery(tt.table, tt.rows))
+		})
+	}
+}
+
+func TestQuoteLiteral(t *testing.T) {
+	assert.Equal(t, `'it''s'`, Dialect{}.QuoteLiteral("it's"))
+	// backslashes are not escapes in SQLite
+	assert.Equal(t, `'C:\dir'`, Dialect{}.QuoteLiteral(`C:\dir`))
+}
@@ -4,17 +4,23 @@ import (
 	"database/sql"
 	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"os"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 
 	"github.com/rezikovka/gonkey/checker/response_body"
+	"github.com/rezikovka/gonkey/checker/response_cookie"
 	"github.com/rezikovka/gonkey/checker/response_db"
 	"github.com/rezikovka/gonkey/checker/response_schema"
 	"github.com/rezikovka/gonkey/fixtures"
+	"github.com/rezikovka/gonkey/output/allure_report"
 	"github.com/rezikovka/gonkey/output/console_colored"
+	"github.com/rezikovka/gonkey/output/junit"
 	"github.com/rezikovka/gonkey/runner"
 	"github.com/rezikovka/gonkey/testloader/yaml_file"
 	"github.com/rezikovka/gonkey/variables"
@@ -29,9 +35,29 @@ func main() {
 		FixturesLocation string
 		EnvFile          string
 		Allure           bool
+		AllureDir        string
+		JUnitFile        string
+		Parallel         int
+		FailFast         bool
+		Timeout          time.Duration
+		TestTimeout      time.Duration
+		Tags             string
+		SkipTags         string
+		RunPattern       string
+		UpdateSnapshots  bool
+		TLSVerify        bool
+		CAFile           string
+		CertFile         string
+		KeyFile          string
+		ServerName       string
+		Proxy            string
+		NoProxy          string
+		HTTP2            bool
 		Verbose          bool
 		Debug            bool
 		DbType           string
+		DbIsolation      string
+		ReuseFixtures    bool
 	}
 
 	flag.StringVar(&config.Host, "host", "", "Target system hostname")
@@ -39,15 +65,40 @@ func main() {
 	flag.StringVar(&config.TestsLocation, "tests", "", "Path to tests file or directory")
 	flag.StringVar(&config.DbDsn, "db_dsn", "", "DSN for the fixtures database (WARNING! Db tables will be truncated)")
 	flag.StringVar(&config.FixturesLocation, "fixtures", "", "Path to fixtures directory")
+	flag.BoolVar(&config.ReuseFixtures, "reuse-fixtures", false, "Don't reload the fixtures of the previous test if their tables are unchanged")
 	flag.StringVar(&config.EnvFile, "env-file", "", "Path to env-file")
 	flag.BoolVar(&config.Allure, "allure", true, "Make Allure report")
+	flag.StringVar(&config.AllureDir, "allure-dir", allure_report.DefaultReportLocation, "Path to Allure results directory")
+	flag.StringVar(&config.JUnitFile, "junit", "", "Path to JUnit XML report file")
+	flag.IntVar(&config.Parallel, "parallel", 1, "Number of test files executed at the same time")
+	flag.BoolVar(&config.FailFast, "fail-fast", false, "Stop on the first test which could not be executed")
+	flag.DurationVar(&config.Timeout, "timeout", 0, "Time limit of the whole run, e.g. 10m (no limit by default)")
+	flag.DurationVar(&config.TestTimeout, "test-timeout", 0, "Time limit of a test which doesn't define its own timeout, e.g. 30s")
+	flag.StringVar(&config.Tags, "tags", "", "Comma-separated list of tags, run only tests having any of them")
+	flag.StringVar(&config.SkipTags, "skip-tags", "", "Comma-separated list of tags, skip tests having any of them")
+	flag.StringVar(&config.RunPattern, "run", "", "Run only tests which names match the regular expression")
+	flag.BoolVar(&config.UpdateSnapshots, "update-snapshots", false, "Overwrite snapshots of responses instead of comparing with them")
+	flag.BoolVar(&config.TLSVerify, "tls-verify", false, "Verify the server certificate (always verified if -ca-file is set)")
+	flag.StringVar(&config.CAFile, "ca-file", "", "Path to PEM file with CA certificates to verify the server")
+	flag.StringVar(&config.CertFile, "cert", "", "Path to PEM file with the client certificate for mutual TLS")
+	flag.StringVar(&config.KeyFile, "key", "", "Path to PEM file with the key of the client certificate")
+	flag.StringVar(&config.ServerName, "server-name", "", "Server name sent by SNI and verified in the server certificate")
+	flag.StringVar(&config.Proxy, "proxy", "", "Proxy URL (HTTP_PROXY and HTTPS_PROXY env vars are used by default)")
+	flag.StringVar(&config.NoProxy, "no-proxy", "", "Comma-separated hosts which are accessed without the proxy (NO_PROXY env var is used by default)")
+	flag.BoolVar(&config.HTTP2, "http2", false, "Try HTTP/2 for TLS connections")
 	flag.BoolVar(&config.Verbose, "v", false, "Verbose output")
 	flag.BoolVar(&config.Debug, "debug", false, "Debug output")
 	flag.StringVar(
-		&config.EnvFile,
+		&config.DbType,
 		"db-type",
 		fixtures.PostgresParam,
-		"Type of database (options: postgres, mysql)",
+		"Type of database (options: postgres, mysql, sqlite)",
+	)
+	flag.StringVar(
+		&config.DbIsolation,
+		"db-isolation",
+		fixtures.IsolationFixturesParam,
+		"Isolation of tests in database (options: fixtures, truncate, snapshot)",
 	)
 
 	flag.Parse()
@@ -65,27 +116,47 @@ func main() {
 		log.Fatal(errors.New("no tests location provided"))
 	}
 
-	var db *sql.DB
-	if config.DbDsn != "" {
-		var err error
-		db, err = sql.Open("postgres", config.DbDsn)
-		if err != nil {
-			log.Fatal(err)
-		}
+	db, dbType, err := openDB(config.DbType, config.DbDsn)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	isolation, err := fixtures.FetchIsolation(config.DbIsolation)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if isolation == fixtures.IsolationSavepoint {
+		// the service doesn't share the connection with gonkey
+		log.Fatal(errors.New("savepoint isolation can be used only when gonkey is used as a library"))
 	}
 
 	var fixturesLoader fixtures.Loader
-	if db != nil && config.FixturesLocation != "" {
-		fixturesLoader = fixtures.NewLoader(&fixtures.Config{
-			DB:       db,
-			Location: config.FixturesLocation,
-			Debug:    config.Debug,
-			DbType:   fixtures.FetchDbType(config.DbType),
-		})
+	if db != nil && (config.FixturesLocation != "" || isolation != fixtures.IsolationFixtures) {
+		fixturesConfig := &fixtures.Config{
+			DB:        db,
+			Location:  config.FixturesLocation,
+			Debug:     config.Debug,
+			DbType:    dbType,
+			Isolation: isolation,
+			Reuse:     config.ReuseFixtures,
+		}
+		if err := fixtures.ValidateConfig(fixturesConfig); err != nil {
+			log.Fatal(fmt.Errorf("-db-isolation %s, -db-type %s: %s", config.DbIsolation, config.DbType, err))
+		}
+		fixturesLoader = fixtures.NewLoader(fixturesConfig)
 	} else if config.FixturesLocation != "" {
 		log.Fatal(errors.New("you should specify db_dsn to load fixtures"))
 	}
 
+	var namePattern *regexp.Regexp
+	if config.RunPattern != "" {
+		var err error
+		namePattern, err = regexp.Compile(config.RunPattern)
+		if err != nil {
+			log.Fatal(fmt.Errorf("invalid -run pattern: %s", err))
+		}
+	}
+
 	if config.EnvFile != "" {
 		if err := godotenv.Load(config.EnvFile); err != nil {
 			log.Println(errors.New("can't load .env file"), err)
@@ -94,9 +165,27 @@ func main() {
 
 	r := runner.New(
 		&runner.Config{
-			Host:           config.Host,
-			FixturesLoader: fixturesLoader,
-			Variables:      variables.New(),
+			Host: config.Host,
+			ClientConfig: &runner.ClientConfig{
+				InsecureSkipVerify: !config.TLSVerify && config.CAFile == "",
+				CAFile:             config.CAFile,
+				CertFile:           config.CertFile,
+				KeyFile:            config.KeyFile,
+				ServerName:         config.ServerName,
+				Proxy:              config.Proxy,
+				NoProxy:            config.NoProxy,
+				HTTP2:              config.HTTP2,
+			},
+			FixturesLoader:  fixturesLoader,
+			Variables:       variables.New(),
+			Concurrency:     config.Parallel,
+			FailFast:        config.FailFast,
+			Timeout:         config.Timeout,
+			TestTimeout:     config.TestTimeout,
+			Tags:            runner.SplitList(config.Tags),
+			SkipTags:        runner.SplitList(config.SkipTags),
+			NamePattern:     namePattern,
+			UpdateSnapshots: config.UpdateSnapshots || os.Getenv("GONKEY_UPDATE_SNAPSHOTS") != "",
 		},
 		yaml_file.NewLoader(config.TestsLocation),
 	)
@@ -104,17 +193,33 @@ func main() {
 	consoleOutput := console_colored.NewOutput(config.Verbose)
 	r.AddOutput(consoleOutput)
 
+	if config.Allure {
+		allureOutput, err := allure_report.NewOutput("Gonkey", config.AllureDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		r.AddOutput(allureOutput)
+	}
+
+	if config.JUnitFile != "" {
+		r.AddOutput(junit.NewOutput(config.JUnitFile))
+	}
+
 	r.AddCheckers(response_body.NewChecker())
+	r.AddCheckers(response_cookie.NewChecker())
 	if config.SpecPath != "" {
 		r.AddCheckers(response_schema.NewChecker(config.SpecPath))
 	}
 
 	if db != nil {
-		r.AddCheckers(response_db.NewChecker(db))
+		r.AddCheckers(response_db.NewCheckerWithDbType(db, dbType))
 	}
 
 	summary, err := r.Run()
 	if err != nil {
+		if summary != nil {
+			consoleOutput.ShowSummary(summary)
+		}
 		log.Fatal(err)
 	}
 
@@ -124,3 +229,19 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// openDB opens the database of the type given by the -db-type param, db is nil if DSN is empty
+func openDB(dbTypeParam, dsn string) (*sql.DB, fixtures.DbType, error) {
+	if dbTypeParam != fixtures.PostgresParam && dbTypeParam != fixtures.MysqlParam && dbTypeParam != fixtures.SqliteParam {
+		return nil, 0, fmt.Errorf("unknown db type %s", dbTypeParam)
+	}
+	dbType := fixtures.FetchDbType(dbTypeParam)
+	if dsn == "" {
+		return nil, dbType, nil
+	}
+	db, err := sql.Open(fixtures.DriverName(dbType), dsn)
+	if err != nil {
+		return nil, 0, err
+	}
+	return db, dbType, nil
+}
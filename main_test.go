@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rezikovka/gonkey/fixtures"
+)
+
+func TestOpenDBMysql(t *testing.T) {
+	db, dbType, err := openDB("mysql", "user:password@tcp(localhost:3306)/test?parseTime=true")
+	require.NoError(t, err)
+	defer db.Close()
+
+	assert.Equal(t, fixtures.DbType(fixtures.Mysql), dbType)
+	assert.IsType(t, &mysql.MySQLDriver{}, db.Driver())
+	assert.NotNil(t, fixtures.NewLoader(&fixtures.Config{DB: db, DbType: dbType}))
+}
+
+func TestOpenDBErrors(t *testing.T) {
+	_, _, err := openDB("mysql", "user:password@tcp(localhost:3306")
+	assert.Error(t, err)
+
+	_, _, err = openDB("oracle", "user:password@localhost/test")
+	assert.EqualError(t, err, "unknown db type oracle")
+}
+
+func TestOpenDBWithoutDsn(t *testing.T) {
+	db, dbType, err := openDB("mysql", "")
+	require.NoError(t, err)
+	assert.Nil(t, db)
+	assert.Equal(t, fixtures.DbType(fixtures.Mysql), dbType)
+}
@@ -0,0 +1,93 @@
+package mocks
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+)
+
+const (
+	// CallsNoConstraint means that the number of calls of a definition is not checked
+	CallsNoConstraint = -1
+	// CallsAtLeastOnce means that the definition fails if it hasn't been called
+	CallsAtLeastOnce = -2
+)
+
+type definition struct {
+	path               string
+	requestConstraints []verifier
+	replyStrategy      replyStrategy
+	sync.Mutex
+	calls           int
+	callsConstraint int
+}
+
+func newDefinition(path string, constraints []verifier, strategy replyStrategy, callsConstraint int) *definition {
+	return &definition{
+		path:               path,
+		requestConstraints: constraints,
+		replyStrategy:      strategy,
+		callsConstraint:    callsConstraint,
+	}
+}
+
+func (d *definition) Execute(w http.ResponseWriter, r *http.Request) []error {
+	d.Lock()
+	d.calls++
+	d.Unlock()
+
+	var errs []error
+	if len(d.requestConstraints) > 0 {
+		requestDump, err := httputil.DumpRequest(r, true)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("at path %s: unable to dump request: %s", d.path, err))
+		}
+		for _, c := range d.requestConstraints {
+			for _, e := range c.Verify(r) {
+				errs = append(errs, &RequestConstraintError{
+					error:       e,
+					Constraint:  c,
+					RequestDump: requestDump,
+				})
+			}
+		}
+	}
+	if d.replyStrategy != nil {
+		errs = append(errs, d.replyStrategy.HandleRequest(w, r)...)
+	}
+	return errs
+}
+
+func (d *definition) ResetRunningContext() {
+	if s, ok := d.replyStrategy.(contextAwareStrategy); ok {
+		s.ResetRunningContext()
+	}
+	d.Lock()
+	d.calls = 0
+	d.Unlock()
+}
+
+func (d *definition) EndRunningContext() []error {
+	d.Lock()
+	calls := d.calls
+	d.Unlock()
+
+	var errs []error
+	// nested definitions can't be called if this one hasn't been called
+	if s, ok := d.replyStrategy.(contextAwareStrategy); ok && calls > 0 {
+		errs = s.EndRunningContext()
+	}
+	switch {
+	case d.callsConstraint >= 0 && calls != d.callsConstraint:
+		errs = append(errs, fmt.Errorf(
+			"at path %s: number of calls does not match: expected %d, actual %d",
+			d.path,
+			d.callsConstraint,
+			calls,
+		))
+	case d.callsConstraint == CallsAtLeastOnce && calls == 0:
+		errs = append(errs, fmt.Errorf("at path %s: definition has not been called", d.path))
+	}
+	return errs
+}
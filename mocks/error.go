@@ -0,0 +1,32 @@
+package mocks
+
+import (
+	"fmt"
+)
+
+// Error is an error which occurred in a mock while the test was running
+type Error struct {
+	error
+	ServiceName string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("mock %s: %s", e.ServiceName, e.error.Error())
+}
+
+// RequestConstraintError is returned when a request to a mock
+// doesn't satisfy one of the request constraints
+type RequestConstraintError struct {
+	error
+	Constraint  verifier
+	RequestDump []byte
+}
+
+func (e *RequestConstraintError) Error() string {
+	return fmt.Sprintf(
+		"request constraint %s: %s, request was:\n\n%s",
+		e.Constraint.Kind(),
+		e.error.Error(),
+		e.RequestDump,
+	)
+}
@@ -0,0 +1,363 @@
+package mocks
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Loader parses mock definitions from tests and sets them to the mocks
+type Loader struct {
+	mocks *Mocks
+}
+
+func NewLoader(mocks *Mocks) *Loader {
+	return &Loader{
+		mocks: mocks,
+	}
+}
+
+// Load sets definitions of the given services,
+// mocks are updated only if all the definitions are valid
+func (l *Loader) Load(mocksDefinition map[string]interface{}) error {
+	definitions := make(map[string]*definition, len(mocksDefinition))
+	for serviceName, rawDefinition := range mocksDefinition {
+		if l.mocks.Service(serviceName) == nil {
+			return fmt.Errorf("unknown mock name: %s", serviceName)
+		}
+		def, err := l.loadDefinition("$", rawDefinition)
+		if err != nil {
+			return fmt.Errorf("unable to load definition for %s: %s", serviceName, err)
+		}
+		definitions[serviceName] = def
+	}
+	for serviceName, def := range definitions {
+		l.mocks.Service(serviceName).SetDefinition(def)
+	}
+	return nil
+}
+
+func (l *Loader) loadDefinition(path string, rawDef interface{}) (*definition, error) {
+	def, ok := rawDef.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("at path %s: definition must be key-values", path)
+	}
+
+	// keys which are allowed in the definition
+	ak := []string{"requestConstraints", "strategy", "calls"}
+
+	// load request constraints
+	var requestConstraints []verifier
+	if constraints, ok := def["requestConstraints"]; ok {
+		var err error
+		requestConstraints, err = l.loadConstraints(path+".requestConstraints", constraints)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// load reply strategy
+	strategyName, ok := def["strategy"].(string)
+	if !ok {
+		return nil, fmt.Errorf("at path %s: `strategy` key is required and must be a string", path)
+	}
+	replyStrategy, err := l.loadStrategy(path, strategyName, def, &ak)
+	if err != nil {
+		return nil, err
+	}
+
+	// load calls constraint, the definition must be called at least once by default
+	callsConstraint := CallsAtLeastOnce
+	if calls, ok := def["calls"]; ok {
+		switch value := calls.(type) {
+		case int:
+			if value < 0 {
+				return nil, fmt.Errorf("at path %s: `calls` must not be negative", path)
+			}
+			callsConstraint = value
+		case string:
+			if value != "any" {
+				return nil, fmt.Errorf("at path %s: `calls` must be an integer or `any`", path)
+			}
+			callsConstraint = CallsNoConstraint
+		default:
+			return nil, fmt.Errorf("at path %s: `calls` must be an integer or `any`", path)
+		}
+	}
+
+	if err := validateMapKeys(path, def, ak...); err != nil {
+		return nil, err
+	}
+
+	return newDefinition(path, requestConstraints, replyStrategy, callsConstraint), nil
+}
+
+func (l *Loader) loadStrategy(path, strategyName string, def map[interface{}]interface{}, ak *[]string) (replyStrategy, error) {
+	path = path + "." + strategyName
+	switch strategyName {
+	case "nop":
+		return newNopReply(), nil
+	case "constant":
+		*ak = append(*ak, "body", "statusCode", "headers")
+		body, err := getRequiredStringKey(path, def, "body")
+		if err != nil {
+			return nil, err
+		}
+		statusCode, headers, err := readReplyParams(path, def)
+		if err != nil {
+			return nil, err
+		}
+		return newConstantReply(body, statusCode, headers), nil
+	case "file":
+		*ak = append(*ak, "filename", "statusCode", "headers")
+		filename, err := getRequiredStringKey(path, def, "filename")
+		if err != nil {
+			return nil, err
+		}
+		statusCode, headers, err := readReplyParams(path, def)
+		if err != nil {
+			return nil, err
+		}
+		strategy, err := newFileReply(filename, statusCode, headers)
+		if err != nil {
+			return nil, fmt.Errorf("at path %s: %s", path, err)
+		}
+		return strategy, nil
+	case "uriVary":
+		*ak = append(*ak, "basePath", "uris")
+		basePath, err := getOptionalStringKey(path, def, "basePath")
+		if err != nil {
+			return nil, err
+		}
+		uris, err := l.loadVariants(path+".uris", def, "uris")
+		if err != nil {
+			return nil, err
+		}
+		return newUriVaryReply(basePath, uris), nil
+	case "methodVary":
+		*ak = append(*ak, "methods")
+		methods, err := l.loadVariants(path+".methods", def, "methods")
+		if err != nil {
+			return nil, err
+		}
+		return newMethodVaryReply(methods), nil
+	case "sequence":
+		*ak = append(*ak, "sequence")
+		rawSequence, ok := def["sequence"].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("at path %s: `sequence` key is required and must be a list", path)
+		}
+		sequence := make([]*definition, len(rawSequence))
+		for i, rawDef := range rawSequence {
+			seqDef, err := l.loadDefinition(fmt.Sprintf("%s.sequence[%d]", path, i), rawDef)
+			if err != nil {
+				return nil, err
+			}
+			sequence[i] = seqDef
+		}
+		return newSequentialReply(sequence), nil
+	default:
+		return nil, fmt.Errorf("at path %s: unknown strategy: %s", path, strategyName)
+	}
+}
+
+func (l *Loader) loadVariants(path string, def map[interface{}]interface{}, key string) (map[string]*definition, error) {
+	rawVariants, ok := def[key].(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("at path %s: `%s` key is required and must be a map", path, key)
+	}
+	variants := make(map[string]*definition, len(rawVariants))
+	for k, rawDef := range rawVariants {
+		name, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("at path %s: key %v must be a string", path, k)
+		}
+		variant, err := l.loadDefinition(path+"."+name, rawDef)
+		if err != nil {
+			return nil, err
+		}
+		variants[name] = variant
+	}
+	return variants, nil
+}
+
+func (l *Loader) loadConstraints(path string, rawConstraints interface{}) ([]verifier, error) {
+	constraints, ok := rawConstraints.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("at path %s: `requestConstraints` must be a list", path)
+	}
+	var res []verifier
+	for i, rawConstraint := range constraints {
+		constraintPath := fmt.Sprintf("%s[%d]", path, i)
+		c, err := l.loadConstraint(constraintPath, rawConstraint)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+	return res, nil
+}
+
+func (l *Loader) loadConstraint(path string, rawConstraint interface{}) (verifier, error) {
+	def, ok := rawConstraint.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("at path %s: constraint definition must be key-values", path)
+	}
+	kind, ok := def["kind"].(string)
+	if !ok {
+		return nil, fmt.Errorf("at path %s: `kind` key is required and must be a string", path)
+	}
+	path = path + "." + kind
+
+	var c verifier
+	var err error
+	ak := []string{"kind"}
+	switch kind {
+	case "nop":
+		c = &nopConstraint{}
+	case "bodyMatchesJSON":
+		ak = append(ak, "body")
+		var body string
+		if body, err = getRequiredStringKey(path, def, "body"); err == nil {
+			c, err = newBodyMatchesJSONConstraint(body)
+			err = pathError(path, err)
+		}
+	case "methodIs":
+		ak = append(ak, "method")
+		var method string
+		if method, err = getRequiredStringKey(path, def, "method"); err == nil {
+			c = &methodConstraint{method: method}
+		}
+	case "methodIsGET":
+		c = &methodConstraint{method: http.MethodGet}
+	case "methodIsPOST":
+		c = &methodConstraint{method: http.MethodPost}
+	case "headerIs":
+		ak = append(ak, "header", "value", "regexp")
+		var header, value, re string
+		if header, err = getRequiredStringKey(path, def, "header"); err != nil {
+			break
+		}
+		if value, err = getOptionalStringKey(path, def, "value"); err != nil {
+			break
+		}
+		if re, err = getOptionalStringKey(path, def, "regexp"); err != nil {
+			break
+		}
+		c, err = newHeaderConstraint(header, value, re)
+		err = pathError(path, err)
+	case "pathMatches":
+		ak = append(ak, "path", "regexp")
+		var p, re string
+		if p, err = getOptionalStringKey(path, def, "path"); err != nil {
+			break
+		}
+		if re, err = getOptionalStringKey(path, def, "regexp"); err != nil {
+			break
+		}
+		if p == "" && re == "" {
+			err = pathError(path, errors.New("`path` or `regexp` key is required"))
+			break
+		}
+		c, err = newPathConstraint(p, re)
+		err = pathError(path, err)
+	case "queryMatches":
+		ak = append(ak, "expectedQuery")
+		var query string
+		if query, err = getRequiredStringKey(path, def, "expectedQuery"); err == nil {
+			c, err = newQueryConstraint(query)
+			err = pathError(path, err)
+		}
+	default:
+		return nil, fmt.Errorf("at path %s: unknown constraint: %s", path, kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := validateMapKeys(path, def, ak...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// pathError adds the path of the definition to the error of the constraint
+func pathError(path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("at path %s: %s", path, err)
+}
+
+// readReplyParams reads status code (200 by default) and headers of a reply
+func readReplyParams(path string, def map[interface{}]interface{}) (int, map[string]string, error) {
+	statusCode := http.StatusOK
+	if rawCode, ok := def["statusCode"]; ok {
+		code, ok := rawCode.(int)
+		if !ok {
+			return 0, nil, fmt.Errorf("at path %s: `statusCode` must be an integer", path)
+		}
+		statusCode = code
+	}
+	var headers map[string]string
+	if rawHeaders, ok := def["headers"]; ok {
+		headersMap, ok := rawHeaders.(map[interface{}]interface{})
+		if !ok {
+			return 0, nil, fmt.Errorf("at path %s: `headers` must be a map", path)
+		}
+		headers = make(map[string]string, len(headersMap))
+		for k, v := range headersMap {
+			key, ok := k.(string)
+			if !ok {
+				return 0, nil, fmt.Errorf("at path %s: header name %v must be a string", path, k)
+			}
+			headers[key] = fmt.Sprintf("%v", v)
+		}
+	}
+	return statusCode, headers, nil
+}
+
+func getRequiredStringKey(path string, def map[interface{}]interface{}, key string) (string, error) {
+	if _, ok := def[key]; !ok {
+		return "", fmt.Errorf("at path %s: `%s` key is required", path, key)
+	}
+	return getOptionalStringKey(path, def, key)
+}
+
+func getOptionalStringKey(path string, def map[interface{}]interface{}, key string) (string, error) {
+	rawValue, ok := def[key]
+	if !ok {
+		return "", nil
+	}
+	value, ok := rawValue.(string)
+	if !ok {
+		return "", fmt.Errorf("at path %s: `%s` must be a string", path, key)
+	}
+	return value, nil
+}
+
+// validateMapKeys fails if the definition has keys not listed as allowed
+func validateMapKeys(path string, def map[interface{}]interface{}, allowedKeys ...string) error {
+	var unexpected []string
+	for k := range def {
+		key, _ := k.(string)
+		if !inArray(key, allowedKeys) {
+			unexpected = append(unexpected, fmt.Sprintf("%v", k))
+		}
+	}
+	if len(unexpected) > 0 {
+		sort.Strings(unexpected)
+		return fmt.Errorf("at path %s: unexpected keys: %s", path, strings.Join(unexpected, ", "))
+	}
+	return nil
+}
+
+func inArray(needle string, haystack []string) bool {
+	for _, e := range haystack {
+		if needle == e {
+			return true
+		}
+	}
+	return false
+}
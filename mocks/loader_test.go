@@ -0,0 +1,130 @@
+package mocks
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestLoaderErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		definitions   string
+		expectedError string
+	}{
+		{
+			name:          "unknown mock",
+			definitions:   "unknown:\n  strategy: nop",
+			expectedError: "unknown mock name: unknown",
+		},
+		{
+			name:          "definition is not a map",
+			definitions:   "service: nop",
+			expectedError: "unable to load definition for service: at path $: definition must be key-values",
+		},
+		{
+			name:          "missing strategy",
+			definitions:   "service:\n  calls: 1",
+			expectedError: "unable to load definition for service: at path $: `strategy` key is required and must be a string",
+		},
+		{
+			name:          "unknown strategy",
+			definitions:   "service:\n  strategy: echo",
+			expectedError: "unable to load definition for service: at path $.echo: unknown strategy: echo",
+		},
+		{
+			name:          "missing strategy param",
+			definitions:   "service:\n  strategy: constant",
+			expectedError: "unable to load definition for service: at path $.constant: `body` key is required",
+		},
+		{
+			name:          "unexpected keys",
+			definitions:   "service:\n  strategy: nop\n  body: ok\n  filename: reply.json",
+			expectedError: "unable to load definition for service: at path $: unexpected keys: body, filename",
+		},
+		{
+			name:          "invalid status code",
+			definitions:   "service:\n  strategy: constant\n  body: ok\n  statusCode: OK",
+			expectedError: "unable to load definition for service: at path $.constant: `statusCode` must be an integer",
+		},
+		{
+			name:          "missing file",
+			definitions:   "service:\n  strategy: file\n  filename: /nonexistent/reply.json",
+			expectedError: "unable to load definition for service: at path $.file: open /nonexistent/reply.json: no such file or directory",
+		},
+		{
+			name:          "negative calls",
+			definitions:   "service:\n  strategy: nop\n  calls: -1",
+			expectedError: "unable to load definition for service: at path $: `calls` must not be negative",
+		},
+		{
+			name:          "invalid calls",
+			definitions:   "service:\n  strategy: nop\n  calls: many",
+			expectedError: "unable to load definition for service: at path $: `calls` must be an integer or `any`",
+		},
+		{
+			name:          "invalid nested definition",
+			definitions:   "service:\n  strategy: uriVary\n  uris:\n    /books:\n      strategy: echo",
+			expectedError: "unable to load definition for service: at path $.uriVary.uris./books.echo: unknown strategy: echo",
+		},
+		{
+			name:          "invalid sequence item",
+			definitions:   "service:\n  strategy: sequence\n  sequence:\n    - strategy: nop\n    - body: ok",
+			expectedError: "unable to load definition for service: at path $.sequence.sequence[1]: `strategy` key is required and must be a string",
+		},
+		{
+			name:          "unknown constraint",
+			definitions:   "service:\n  strategy: nop\n  requestConstraints:\n    - kind: bodyIs",
+			expectedError: "unable to load definition for service: at path $.requestConstraints[0].bodyIs: unknown constraint: bodyIs",
+		},
+		{
+			name:          "missing constraint param",
+			definitions:   "service:\n  strategy: nop\n  requestConstraints:\n    - kind: headerIs",
+			expectedError: "unable to load definition for service: at path $.requestConstraints[0].headerIs: `header` key is required",
+		},
+		{
+			name:          "path constraint without path",
+			definitions:   "service:\n  strategy: nop\n  requestConstraints:\n    - kind: pathMatches",
+			expectedError: "unable to load definition for service: at path $.requestConstraints[0].pathMatches: `path` or `regexp` key is required",
+		},
+		{
+			name:          "invalid regexp",
+			definitions:   "service:\n  strategy: nop\n  requestConstraints:\n    - kind: pathMatches\n      regexp: '['",
+			expectedError: "unable to load definition for service: at path $.requestConstraints[0].pathMatches: error parsing regexp: missing closing ]: `[`",
+		},
+		{
+			name:          "invalid JSON of constraint",
+			definitions:   "service:\n  strategy: nop\n  requestConstraints:\n    - kind: bodyMatchesJSON\n      body: '{'",
+			expectedError: "unable to load definition for service: at path $.requestConstraints[0].bodyMatchesJSON: unexpected end of JSON input",
+		},
+		{
+			name:          "unexpected keys of constraint",
+			definitions:   "service:\n  strategy: nop\n  requestConstraints:\n    - kind: methodIsGET\n      method: POST",
+			expectedError: "unable to load definition for service: at path $.requestConstraints[0].methodIsGET: unexpected keys: method",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var raw map[string]interface{}
+			require.NoError(t, yaml.Unmarshal([]byte(tt.definitions), &raw))
+
+			err := NewLoader(NewNop("service")).Load(raw)
+			assert.EqualError(t, err, tt.expectedError)
+		})
+	}
+}
+
+func TestLoaderKeepsDefinitionsOnError(t *testing.T) {
+	m := NewNop("first", "second")
+	var raw map[string]interface{}
+	require.NoError(t, yaml.Unmarshal([]byte("first:\n  strategy: nop\nsecond:\n  strategy: echo"), &raw))
+
+	require.Error(t, NewLoader(m).Load(raw))
+
+	// the valid definition isn't set, since the other one is invalid
+	serve(t, m.Service("first"), mockRequest{target: "/", expectedStatus: http.StatusServiceUnavailable})
+}
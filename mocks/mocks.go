@@ -0,0 +1,79 @@
+package mocks
+
+type Mocks struct {
+	mocks map[string]*ServiceMock
+}
+
+// New creates a set of mocks from the given service mocks
+func New(mocks ...*ServiceMock) *Mocks {
+	mocksMap := make(map[string]*ServiceMock, len(mocks))
+	for _, v := range mocks {
+		mocksMap[v.ServiceName] = v
+	}
+	return &Mocks{
+		mocks: mocksMap,
+	}
+}
+
+// NewNop creates mocks for the given services which fail on any request
+// until a definition is loaded from a test
+func NewNop(serviceNames ...string) *Mocks {
+	mocksMap := make(map[string]*ServiceMock, len(serviceNames))
+	for _, name := range serviceNames {
+		mocksMap[name] = NewServiceMock(name, newDefinition("$", nil, &failReply{}, CallsNoConstraint))
+	}
+	return &Mocks{
+		mocks: mocksMap,
+	}
+}
+
+// Start starts the server of every mock on a random local port
+func (m *Mocks) Start() error {
+	for _, v := range m.mocks {
+		if err := v.StartServer(); err != nil {
+			m.Shutdown()
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown stops the servers of all mocks
+func (m *Mocks) Shutdown() {
+	for _, v := range m.mocks {
+		v.ShutdownServer()
+	}
+}
+
+func (m *Mocks) SetMock(mock *ServiceMock) {
+	m.mocks[mock.ServiceName] = mock
+}
+
+// Service returns the mock of the service with the given name or nil
+func (m *Mocks) Service(serviceName string) *ServiceMock {
+	return m.mocks[serviceName]
+}
+
+// ResetDefinitions restores default definitions of all mocks,
+// so definitions of the previous test don't leak into the next one
+func (m *Mocks) ResetDefinitions() {
+	for _, v := range m.mocks {
+		v.ResetDefinition()
+	}
+}
+
+// ResetRunningContext clears calls counters and errors of all mocks
+func (m *Mocks) ResetRunningContext() {
+	for _, v := range m.mocks {
+		v.ResetRunningContext()
+	}
+}
+
+// EndRunningContext returns errors collected by all mocks since the last reset
+func (m *Mocks) EndRunningContext() []error {
+	var errs []error
+	for _, v := range m.mocks {
+		errs = append(errs, v.EndRunningContext()...)
+	}
+	return errs
+}
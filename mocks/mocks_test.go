@@ -0,0 +1,381 @@
+package mocks
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+type mockRequest struct {
+	method  string
+	target  string
+	body    string
+	headers map[string]string
+
+	expectedStatus int
+	expectedBody   string
+}
+
+// loadMocks makes the nop mock of the service and loads the definition into it
+func loadMocks(t *testing.T, service, definition string) *Mocks {
+	var raw map[string]interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(service+":\n"+definition), &raw))
+
+	m := NewNop(service)
+	require.NoError(t, NewLoader(m).Load(raw))
+	return m
+}
+
+// serve sends the request to the mock and checks the response
+func serve(t *testing.T, m *ServiceMock, r mockRequest) {
+	method := r.method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req := httptest.NewRequest(method, r.target, strings.NewReader(r.body))
+	for k, v := range r.headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+
+	assert.Equal(t, r.expectedStatus, rec.Code, "%s %s", method, r.target)
+	if r.expectedBody != "" {
+		assert.Equal(t, r.expectedBody, rec.Body.String(), "%s %s", method, r.target)
+	}
+}
+
+// errorStrings returns sorted messages of the errors, since nested definitions are checked in random order
+func errorStrings(errs []error) []string {
+	var res []string
+	for _, err := range errs {
+		res = append(res, err.Error())
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestStrategies(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gonkey-mocks")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "reply.json")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(`{"from": "file"}`), 0644))
+
+	tests := []struct {
+		name           string
+		definition     string
+		requests       []mockRequest
+		expectedErrors []string
+	}{
+		{
+			name: "nop",
+			definition: `
+  strategy: nop`,
+			requests: []mockRequest{{target: "/any", expectedStatus: http.StatusNoContent}},
+		},
+		{
+			name: "constant",
+			definition: `
+  strategy: constant
+  body: '{"ok": true}'
+  statusCode: 201
+  headers:
+    Content-Type: application/json`,
+			requests: []mockRequest{{target: "/any", expectedStatus: http.StatusCreated, expectedBody: `{"ok": true}`}},
+		},
+		{
+			name: "file",
+			definition: `
+  strategy: file
+  filename: ` + filename,
+			requests: []mockRequest{{target: "/any", expectedStatus: http.StatusOK, expectedBody: `{"from": "file"}`}},
+		},
+		{
+			name: "uriVary",
+			definition: `
+  strategy: uriVary
+  basePath: /api
+  uris:
+    /books:
+      strategy: constant
+      body: books
+    /shelves:
+      strategy: constant
+      body: shelves
+    /authors:
+      calls: any
+      strategy: nop`,
+			requests: []mockRequest{
+				{target: "/api/books", expectedStatus: http.StatusOK, expectedBody: "books"},
+				{target: "/api/users", expectedStatus: http.StatusNotFound},
+			},
+			expectedErrors: []string{
+				"mock service: at path $.uriVary.uris./shelves: definition has not been called",
+				"mock service: unexpected request: GET /api/users",
+			},
+		},
+		{
+			name: "methodVary",
+			definition: `
+  strategy: methodVary
+  methods:
+    GET:
+      strategy: constant
+      body: got
+    POST:
+      strategy: constant
+      body: posted
+      statusCode: 201`,
+			requests: []mockRequest{
+				{method: http.MethodGet, target: "/", expectedStatus: http.StatusOK, expectedBody: "got"},
+				{method: http.MethodPost, target: "/", expectedStatus: http.StatusCreated, expectedBody: "posted"},
+				{method: http.MethodDelete, target: "/", expectedStatus: http.StatusMethodNotAllowed},
+			},
+			expectedErrors: []string{"mock service: unexpected request: DELETE /"},
+		},
+		{
+			name: "sequence is partly consumed",
+			definition: `
+  strategy: sequence
+  sequence:
+    - strategy: constant
+      body: first
+    - strategy: constant
+      body: second
+    - strategy: constant
+      body: third`,
+			requests: []mockRequest{
+				{target: "/", expectedStatus: http.StatusOK, expectedBody: "first"},
+				{target: "/", expectedStatus: http.StatusOK, expectedBody: "second"},
+			},
+			expectedErrors: []string{"mock service: at path $.sequence.sequence[2]: definition has not been called"},
+		},
+		{
+			name: "sequence is exceeded",
+			definition: `
+  strategy: sequence
+  sequence:
+    - strategy: constant
+      body: first`,
+			requests: []mockRequest{
+				{target: "/", expectedStatus: http.StatusOK, expectedBody: "first"},
+				{target: "/more", expectedStatus: http.StatusNotFound},
+			},
+			expectedErrors: []string{"mock service: unexpected request: GET /more"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := loadMocks(t, "service", tt.definition)
+			for _, r := range tt.requests {
+				serve(t, m.Service("service"), r)
+			}
+			assert.Equal(t, tt.expectedErrors, errorStrings(m.EndRunningContext()))
+		})
+	}
+}
+
+func TestCalls(t *testing.T) {
+	tests := []struct {
+		name           string
+		definition     string
+		calls          int
+		expectedErrors []string
+	}{
+		{
+			name:           "not called",
+			definition:     "  strategy: nop",
+			expectedErrors: []string{"mock service: at path $: definition has not been called"},
+		},
+		{
+			name:       "called",
+			definition: "  strategy: nop",
+			calls:      3,
+		},
+		{
+			name:       "any number of calls",
+			definition: "  strategy: nop\n  calls: any",
+		},
+		{
+			name:       "exact number of calls",
+			definition: "  strategy: nop\n  calls: 2",
+			calls:      2,
+		},
+		{
+			name:           "number of calls does not match",
+			definition:     "  strategy: nop\n  calls: 2",
+			calls:          1,
+			expectedErrors: []string{"mock service: at path $: number of calls does not match: expected 2, actual 1"},
+		},
+		{
+			name:       "no calls are expected",
+			definition: "  strategy: nop\n  calls: 0",
+		},
+		{
+			name: "nested definitions of the definition which has not been called",
+			definition: `
+  calls: 0
+  strategy: uriVary
+  uris:
+    /books:
+      strategy: nop`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := loadMocks(t, "service", tt.definition)
+			for i := 0; i < tt.calls; i++ {
+				serve(t, m.Service("service"), mockRequest{target: "/", expectedStatus: http.StatusNoContent})
+			}
+			assert.Equal(t, tt.expectedErrors, errorStrings(m.EndRunningContext()))
+		})
+	}
+}
+
+func TestResetRunningContext(t *testing.T) {
+	m := loadMocks(t, "service", `
+  calls: 2
+  strategy: sequence
+  sequence:
+    - strategy: constant
+      body: first
+    - strategy: constant
+      body: second`)
+	service := m.Service("service")
+
+	serve(t, service, mockRequest{target: "/", expectedStatus: http.StatusOK, expectedBody: "first"})
+	m.ResetRunningContext()
+	serve(t, service, mockRequest{target: "/", expectedStatus: http.StatusOK, expectedBody: "first"})
+	serve(t, service, mockRequest{target: "/", expectedStatus: http.StatusOK, expectedBody: "second"})
+	assert.Empty(t, m.EndRunningContext())
+
+	// the default definition fails on any request
+	m.ResetDefinitions()
+	m.ResetRunningContext()
+	serve(t, service, mockRequest{target: "/", expectedStatus: http.StatusServiceUnavailable})
+	assert.Equal(t, []string{"mock service: unexpected request: GET /"}, errorStrings(m.EndRunningContext()))
+}
+
+func TestRequestConstraints(t *testing.T) {
+	tests := []struct {
+		name           string
+		constraint     string
+		request        mockRequest
+		expectedErrors []string
+	}{
+		{
+			name:       "methodIs",
+			constraint: "kind: methodIs\n      method: put",
+			request:    mockRequest{method: http.MethodPut, target: "/"},
+		},
+		{
+			name:           "methodIsPOST fails",
+			constraint:     "kind: methodIsPOST",
+			request:        mockRequest{target: "/"},
+			expectedErrors: []string{"request constraint methodIs: method does not match: expected POST, actual GET"},
+		},
+		{
+			name:       "headerIs",
+			constraint: "kind: headerIs\n      header: X-Token\n      value: secret",
+			request:    mockRequest{target: "/", headers: map[string]string{"X-Token": "secret"}},
+		},
+		{
+			name:           "headerIs is missing",
+			constraint:     "kind: headerIs\n      header: X-Token",
+			request:        mockRequest{target: "/"},
+			expectedErrors: []string{"request constraint headerIs: request doesn't have header X-Token"},
+		},
+		{
+			name:           "headerIs doesn't match regexp",
+			constraint:     "kind: headerIs\n      header: X-Token\n      regexp: ^[0-9]+$",
+			request:        mockRequest{target: "/", headers: map[string]string{"X-Token": "secret"}},
+			expectedErrors: []string{"request constraint headerIs: X-Token header value secret doesn't match regexp ^[0-9]+$"},
+		},
+		{
+			name:       "pathMatches",
+			constraint: "kind: pathMatches\n      regexp: ^/books/[0-9]+$",
+			request:    mockRequest{target: "/books/42"},
+		},
+		{
+			name:           "pathMatches fails",
+			constraint:     "kind: pathMatches\n      path: /books",
+			request:        mockRequest{target: "/shelves"},
+			expectedErrors: []string{"request constraint pathMatches: url path /shelves doesn't match expected /books"},
+		},
+		{
+			name:       "queryMatches ignores order of values",
+			constraint: "kind: queryMatches\n      expectedQuery: ?id=1&id=2",
+			request:    mockRequest{target: "/?id=2&id=1&page=3"},
+		},
+		{
+			name:           "queryMatches fails",
+			constraint:     "kind: queryMatches\n      expectedQuery: id=1",
+			request:        mockRequest{target: "/?page=3"},
+			expectedErrors: []string{"request constraint queryMatches: 'id' parameter is missing in request query"},
+		},
+		{
+			name:       "bodyMatchesJSON",
+			constraint: "kind: bodyMatchesJSON\n      body: '{\"id\": 1}'",
+			request:    mockRequest{method: http.MethodPost, target: "/", body: `{"id": 1, "name": "book"}`},
+		},
+		{
+			name:           "bodyMatchesJSON with empty body",
+			constraint:     "kind: bodyMatchesJSON\n      body: '{\"id\": 1}'",
+			request:        mockRequest{method: http.MethodPost, target: "/"},
+			expectedErrors: []string{"request constraint bodyMatchesJSON: request is empty"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := loadMocks(t, "service", `
+  requestConstraints:
+    - `+tt.constraint+`
+  strategy: constant
+  body: ok`)
+			tt.request.expectedStatus = http.StatusOK
+			serve(t, m.Service("service"), tt.request)
+
+			errs := m.EndRunningContext()
+			require.Len(t, errs, len(tt.expectedErrors))
+			for i, err := range errs {
+				// the error is followed by the dump of the request
+				assert.True(t, strings.HasPrefix(err.Error(), "mock service: "+tt.expectedErrors[i]), err.Error())
+			}
+		})
+	}
+}
+
+func TestServer(t *testing.T) {
+	m := loadMocks(t, "service", `
+  strategy: constant
+  body: ok`)
+	service := m.Service("service")
+	assert.Equal(t, "", service.ServerAddr())
+
+	require.NoError(t, m.Start())
+	defer m.Shutdown()
+
+	resp, err := http.Get("http://" + service.ServerAddr() + "/")
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(body))
+	assert.Empty(t, m.EndRunningContext())
+
+	m.Shutdown()
+	assert.Equal(t, "", service.ServerAddr())
+}
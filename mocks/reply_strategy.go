@@ -0,0 +1,192 @@
+package mocks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type replyStrategy interface {
+	HandleRequest(w http.ResponseWriter, r *http.Request) []error
+}
+
+// contextAwareStrategy is implemented by strategies which hold
+// nested definitions or state between requests within a test
+type contextAwareStrategy interface {
+	ResetRunningContext()
+	EndRunningContext() []error
+}
+
+func unhandledRequestError(r *http.Request) []error {
+	return []error{fmt.Errorf("unexpected request: %s %s", r.Method, r.URL.RequestURI())}
+}
+
+// failReply fails on any request, it is used by mocks
+// which don't have a definition for the current test
+type failReply struct{}
+
+func (s *failReply) HandleRequest(w http.ResponseWriter, r *http.Request) []error {
+	w.WriteHeader(http.StatusServiceUnavailable)
+	return unhandledRequestError(r)
+}
+
+type nopReply struct{}
+
+func newNopReply() replyStrategy {
+	return &nopReply{}
+}
+
+func (s *nopReply) HandleRequest(w http.ResponseWriter, r *http.Request) []error {
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+type constantReply struct {
+	replyBody  []byte
+	statusCode int
+	headers    map[string]string
+}
+
+func newConstantReply(body string, statusCode int, headers map[string]string) replyStrategy {
+	return &constantReply{
+		replyBody:  []byte(body),
+		statusCode: statusCode,
+		headers:    headers,
+	}
+}
+
+func newFileReply(filename string, statusCode int, headers map[string]string) (replyStrategy, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &constantReply{
+		replyBody:  content,
+		statusCode: statusCode,
+		headers:    headers,
+	}, nil
+}
+
+func (s *constantReply) HandleRequest(w http.ResponseWriter, r *http.Request) []error {
+	for k, v := range s.headers {
+		w.Header().Add(k, v)
+	}
+	w.WriteHeader(s.statusCode)
+	_, _ = w.Write(s.replyBody)
+	return nil
+}
+
+type uriVaryReply struct {
+	basePath string
+	variants map[string]*definition
+}
+
+func newUriVaryReply(basePath string, variants map[string]*definition) replyStrategy {
+	return &uriVaryReply{
+		basePath: strings.TrimRight(basePath, "/") + "/",
+		variants: variants,
+	}
+}
+
+func (s *uriVaryReply) HandleRequest(w http.ResponseWriter, r *http.Request) []error {
+	for uri, def := range s.variants {
+		uri = strings.TrimLeft(uri, "/")
+		if s.basePath+uri == r.URL.Path {
+			return def.Execute(w, r)
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+	return unhandledRequestError(r)
+}
+
+func (s *uriVaryReply) ResetRunningContext() {
+	for _, def := range s.variants {
+		def.ResetRunningContext()
+	}
+}
+
+func (s *uriVaryReply) EndRunningContext() []error {
+	var errs []error
+	for _, def := range s.variants {
+		errs = append(errs, def.EndRunningContext()...)
+	}
+	return errs
+}
+
+type methodVaryReply struct {
+	variants map[string]*definition
+}
+
+func newMethodVaryReply(variants map[string]*definition) replyStrategy {
+	return &methodVaryReply{
+		variants: variants,
+	}
+}
+
+func (s *methodVaryReply) HandleRequest(w http.ResponseWriter, r *http.Request) []error {
+	for method, def := range s.variants {
+		if strings.EqualFold(method, r.Method) {
+			return def.Execute(w, r)
+		}
+	}
+	w.WriteHeader(http.StatusMethodNotAllowed)
+	return unhandledRequestError(r)
+}
+
+func (s *methodVaryReply) ResetRunningContext() {
+	for _, def := range s.variants {
+		def.ResetRunningContext()
+	}
+}
+
+func (s *methodVaryReply) EndRunningContext() []error {
+	var errs []error
+	for _, def := range s.variants {
+		errs = append(errs, def.EndRunningContext()...)
+	}
+	return errs
+}
+
+type sequentialReply struct {
+	sync.Mutex
+	count    int
+	sequence []*definition
+}
+
+func newSequentialReply(sequence []*definition) replyStrategy {
+	return &sequentialReply{
+		sequence: sequence,
+	}
+}
+
+func (s *sequentialReply) HandleRequest(w http.ResponseWriter, r *http.Request) []error {
+	s.Lock()
+	defer s.Unlock()
+	// out of bounds, url requested more times than sequence length
+	if s.count >= len(s.sequence) {
+		w.WriteHeader(http.StatusNotFound)
+		return unhandledRequestError(r)
+	}
+	def := s.sequence[s.count]
+	s.count++
+	return def.Execute(w, r)
+}
+
+func (s *sequentialReply) ResetRunningContext() {
+	s.Lock()
+	s.count = 0
+	s.Unlock()
+	for _, def := range s.sequence {
+		def.ResetRunningContext()
+	}
+}
+
+func (s *sequentialReply) EndRunningContext() []error {
+	var errs []error
+	for _, def := range s.sequence {
+		errs = append(errs, def.EndRunningContext()...)
+	}
+	return errs
+}
@@ -0,0 +1,222 @@
+package mocks
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/rezikovka/gonkey/compare"
+)
+
+type verifier interface {
+	Kind() string
+	Verify(r *http.Request) []error
+}
+
+type nopConstraint struct{}
+
+func (c *nopConstraint) Kind() string {
+	return "nop"
+}
+
+func (c *nopConstraint) Verify(r *http.Request) []error {
+	return nil
+}
+
+type bodyMatchesJSONConstraint struct {
+	expectedBody interface{}
+}
+
+func newBodyMatchesJSONConstraint(expected string) (verifier, error) {
+	var expectedBody interface{}
+	if err := json.Unmarshal([]byte(expected), &expectedBody); err != nil {
+		return nil, err
+	}
+	return &bodyMatchesJSONConstraint{
+		expectedBody: expectedBody,
+	}, nil
+}
+
+func (c *bodyMatchesJSONConstraint) Kind() string {
+	return "bodyMatchesJSON"
+}
+
+func (c *bodyMatchesJSONConstraint) Verify(r *http.Request) []error {
+	body, err := readBody(r)
+	if err != nil {
+		return []error{err}
+	}
+	if len(body) == 0 {
+		return []error{errors.New("request is empty")}
+	}
+	var actual interface{}
+	if err := json.Unmarshal(body, &actual); err != nil {
+		return []error{err}
+	}
+	return compare.Compare(c.expectedBody, actual, compare.CompareParams{})
+}
+
+type methodConstraint struct {
+	method string
+}
+
+func (c *methodConstraint) Kind() string {
+	return "methodIs"
+}
+
+func (c *methodConstraint) Verify(r *http.Request) []error {
+	if !strings.EqualFold(r.Method, c.method) {
+		return []error{fmt.Errorf("method does not match: expected %s, actual %s", c.method, r.Method)}
+	}
+	return nil
+}
+
+type headerConstraint struct {
+	header string
+	value  string
+	regexp *regexp.Regexp
+}
+
+func newHeaderConstraint(header, value, re string) (verifier, error) {
+	var reCompiled *regexp.Regexp
+	if re != "" {
+		var err error
+		reCompiled, err = regexp.Compile(re)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &headerConstraint{
+		header: header,
+		value:  value,
+		regexp: reCompiled,
+	}, nil
+}
+
+func (c *headerConstraint) Kind() string {
+	return "headerIs"
+}
+
+func (c *headerConstraint) Verify(r *http.Request) []error {
+	value := r.Header.Get(c.header)
+	if value == "" {
+		return []error{fmt.Errorf("request doesn't have header %s", c.header)}
+	}
+	if c.value != "" && c.value != value {
+		return []error{fmt.Errorf("%s header value %s doesn't match expected %s", c.header, value, c.value)}
+	}
+	if c.regexp != nil && !c.regexp.MatchString(value) {
+		return []error{fmt.Errorf("%s header value %s doesn't match regexp %s", c.header, value, c.regexp)}
+	}
+	return nil
+}
+
+type pathConstraint struct {
+	path   string
+	regexp *regexp.Regexp
+}
+
+func newPathConstraint(path, re string) (verifier, error) {
+	var reCompiled *regexp.Regexp
+	if re != "" {
+		var err error
+		reCompiled, err = regexp.Compile(re)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &pathConstraint{
+		path:   path,
+		regexp: reCompiled,
+	}, nil
+}
+
+func (c *pathConstraint) Kind() string {
+	return "pathMatches"
+}
+
+func (c *pathConstraint) Verify(r *http.Request) []error {
+	path := r.URL.Path
+	if c.path != "" && c.path != path {
+		return []error{fmt.Errorf("url path %s doesn't match expected %s", path, c.path)}
+	}
+	if c.regexp != nil && !c.regexp.MatchString(path) {
+		return []error{fmt.Errorf("url path %s doesn't match regexp %s", path, c.regexp)}
+	}
+	return nil
+}
+
+type queryConstraint struct {
+	expectedQuery url.Values
+}
+
+func newQueryConstraint(query string) (verifier, error) {
+	query = strings.TrimLeft(query, "?")
+	expectedQuery, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return &queryConstraint{
+		expectedQuery: expectedQuery,
+	}, nil
+}
+
+func (c *queryConstraint) Kind() string {
+	return "queryMatches"
+}
+
+func (c *queryConstraint) Verify(r *http.Request) []error {
+	var errs []error
+	gotQuery := r.URL.Query()
+	for key, want := range c.expectedQuery {
+		got, ok := gotQuery[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("'%s' parameter is missing in request query", key))
+			continue
+		}
+		if !equalUnordered(want, got) {
+			errs = append(errs, fmt.Errorf("'%s' parameters are not equal.\n Got: %s \n Want: %s", key, got, want))
+		}
+	}
+	return errs
+}
+
+func equalUnordered(want, got []string) bool {
+	if len(want) != len(got) {
+		return false
+	}
+	counts := make(map[string]int, len(want))
+	for _, v := range want {
+		counts[v]++
+	}
+	for _, v := range got {
+		counts[v]--
+	}
+	for _, c := range counts {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// readBody reads the request body and restores it,
+// so other constraints and the strategy can read it again
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	_ = r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
@@ -0,0 +1,102 @@
+package mocks
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+)
+
+type ServiceMock struct {
+	server            *http.Server
+	listener          net.Listener
+	mock              *definition
+	defaultDefinition *definition
+	sync.Mutex
+	errors []error
+
+	ServiceName string
+}
+
+func NewServiceMock(serviceName string, mock *definition) *ServiceMock {
+	return &ServiceMock{
+		mock:              mock,
+		defaultDefinition: mock,
+		ServiceName:       serviceName,
+	}
+}
+
+func (m *ServiceMock) StartServer() error {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("unable to start mock %s: %s", m.ServiceName, err)
+	}
+	m.listener = ln
+	m.server = &http.Server{Addr: ln.Addr().String(), Handler: m}
+	go func() { _ = m.server.Serve(ln) }()
+	return nil
+}
+
+func (m *ServiceMock) ShutdownServer() {
+	if m.server == nil {
+		return
+	}
+	_ = m.server.Close()
+	m.server = nil
+	m.listener = nil
+}
+
+// ServerAddr returns host:port the mock server listens on, it's empty if the server isn't started
+func (m *ServiceMock) ServerAddr() string {
+	if m.listener == nil {
+		return ""
+	}
+	return m.listener.Addr().String()
+}
+
+func (m *ServiceMock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.mock != nil {
+		errs := m.mock.Execute(w, r)
+		m.errors = append(m.errors, errs...)
+	}
+}
+
+func (m *ServiceMock) SetDefinition(newDefinition *definition) {
+	m.Lock()
+	defer m.Unlock()
+	m.mock = newDefinition
+}
+
+func (m *ServiceMock) ResetDefinition() {
+	m.Lock()
+	defer m.Unlock()
+	m.mock = m.defaultDefinition
+}
+
+func (m *ServiceMock) ResetRunningContext() {
+	m.Lock()
+	defer m.Unlock()
+	m.errors = nil
+	if m.mock != nil {
+		m.mock.ResetRunningContext()
+	}
+}
+
+func (m *ServiceMock) EndRunningContext() []error {
+	m.Lock()
+	defer m.Unlock()
+	errs := m.errors
+	if m.mock != nil {
+		errs = append(errs, m.mock.EndRunningContext()...)
+	}
+	for i, e := range errs {
+		errs[i] = &Error{
+			error:       e,
+			ServiceName: m.ServiceName,
+		}
+	}
+	return errs
+}
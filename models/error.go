@@ -0,0 +1,58 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Stages of the test execution
+const (
+	StageFixtures     = "fixtures"
+	StageMocks        = "mocks"
+	StageRequestBuild = "request build"
+	StageTransport    = "transport"
+	StageChecker      = "checker"
+	StageVariables    = "variables"
+	StagePause        = "pause"
+	StageRetry        = "retry"
+)
+
+// ExecutionError is an error which interrupted the test execution at some stage
+type ExecutionError struct {
+	Stage string
+	Err   error
+}
+
+func NewExecutionError(stage string, err error) *ExecutionError {
+	return &ExecutionError{
+		Stage: stage,
+		Err:   err,
+	}
+}
+
+func (e *ExecutionError) Error() string {
+	return fmt.Sprintf("%s stage failed: %s", e.Stage, e.Err.Error())
+}
+
+func (e *ExecutionError) Unwrap() error {
+	return e.Err
+}
+
+// TimeoutError is an error caused by exceeding the time limit of the test or cancellation of the whole run
+type TimeoutError struct {
+	Stage string
+	// Timeout of the test, zero if the whole run has been interrupted
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Timeout > 0 {
+		return fmt.Sprintf("test timed out after %s at %s stage", e.Timeout, e.Stage)
+	}
+	return fmt.Sprintf("run interrupted at %s stage: %s", e.Stage, e.Err.Error())
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
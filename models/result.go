@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // Result of test execution
 type Result struct {
 	Path                string // TODO: remove
@@ -14,9 +16,20 @@ type Result struct {
 	DbResponse          []string
 	Errors              []error
 	Test                TestInterface
+	Attempts            int
+	StartTime           time.Time
+	Duration            time.Duration
+	SkipReason          string
+	CapturedVariables   map[string]string // values captured by $capture in the expected response
+	Steps               []*Result         // results of the executed steps of the scenario
 }
 
 // Passed returns true if test passed (false otherwise)
 func (r *Result) Passed() bool {
 	return len(r.Errors) == 0
 }
+
+// Skipped returns true if test was skipped without running
+func (r *Result) Skipped() bool {
+	return r.SkipReason != ""
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // Common Test interface
 type TestInterface interface {
 	ToQuery() string
@@ -10,10 +12,29 @@ type TestInterface interface {
 	GetResponses() map[int]string
 	GetResponse(code int) (string, bool)
 	GetResponseHeaders(code int) (map[string]string, bool)
+	GetResponseCookies(code int) (map[string]*CookieAssertion, bool)
+	GetResponseAssertions(code int) ([]*ResponseAssertion, bool)
+	// Snapshot returns true if bodies of responses of any status are compared with snapshots
+	Snapshot() bool
+	// SnapshotMask returns paths of volatile values which are not stored in snapshots
+	SnapshotMask() []string
 	GetName() string
+	GetFileName() string
+	Tags() []string
+	// SkipReason returns the reason why the test must be skipped, the test is run if it's empty
+	SkipReason() string
+	// Only returns true if the test is focused, then only focused tests are run
+	Only() bool
 	Fixtures() []string
 	Pause() int
+	Retry() *RetryParams
+	Timeout() time.Duration
+	Parallel() bool
+	// FollowRedirects returns true if the client must follow redirects of the test request
+	FollowRedirects() bool
 	Cookies() map[string]string
+	// CookieJar returns the mode of keeping cookies set by responses, cookies are not kept if empty
+	CookieJar() string
 	Headers() map[string]string
 	ContentType() string
 	GetForm() *Form
@@ -21,6 +42,9 @@ type TestInterface interface {
 	DbResponseJson() []string
 	GetVariables() map[string]string
 	GetVariablesToSet() map[int]map[string]string
+	// Steps returns steps of the scenario, they are executed instead of the test request
+	Steps() []TestInterface
+	ServiceMocks() map[string]interface{}
 
 	// setters
 	SetQuery(string)
@@ -45,8 +69,85 @@ type Form struct {
 	Files map[string]string `json:"files" yaml:"files"`
 }
 
+// SnapshotResponse is the expected response which is compared with the stored snapshot
+const SnapshotResponse = "$snapshot"
+
+// Cookie jar modes
+const (
+	// cookies are shared by the tests of the same file which use this mode
+	CookieJarFile = "file"
+	// cookies are shared by the steps of the scenario
+	CookieJarScenario = "scenario"
+)
+
+// CookieAssertion describes the cookie which must be set by the response,
+// empty fields are not checked, Value and Expires are checked if they are set, even to the empty string
+type CookieAssertion struct {
+	// Value may be a pattern: $matchRegexp(...)
+	Value    *string `json:"value" yaml:"value"`
+	Path     string  `json:"path" yaml:"path"`
+	Domain   string  `json:"domain" yaml:"domain"`
+	HttpOnly *bool   `json:"httpOnly" yaml:"httpOnly"`
+	Secure   *bool   `json:"secure" yaml:"secure"`
+	// SameSite is one of Lax, Strict, None
+	SameSite string `json:"sameSite" yaml:"sameSite"`
+	// Session is true if the cookie must have neither Expires nor Max-Age
+	Session *bool `json:"session" yaml:"session"`
+	// MaxAge is the value of Max-Age, zero means the cookie is deleted
+	MaxAge *int `json:"maxAge" yaml:"maxAge"`
+	// Expires is the value of Expires, it may be a pattern: $matchRegexp(...), empty string means no Expires
+	Expires *string `json:"expires" yaml:"expires"`
+}
+
+// ResponseAssertion checks the value at the path of the JSON response body,
+// all the set operators must pass
+type ResponseAssertion struct {
+	// Path is in gjson syntax, e.g. data.items.0.id
+	Path   string      `json:"path" yaml:"path"`
+	Equals interface{} `json:"equals" yaml:"equals"`
+	// EqualsSet tells that Equals is checked even if it's nil, so the value must be null.
+	// It's set when the assertion is decoded from YAML with the key equals.
+	EqualsSet bool   `json:"-" yaml:"-"`
+	Regex     string `json:"regex" yaml:"regex"`
+	// Exists is false if the value must be missing
+	Exists *bool `json:"exists" yaml:"exists"`
+	// Length is the length of the array, the string or the number of keys of the object
+	Length      *int     `json:"length" yaml:"length"`
+	GreaterThan *float64 `json:"greaterThan" yaml:"greaterThan"`
+	LessThan    *float64 `json:"lessThan" yaml:"lessThan"`
+	// Contains is the element of the array or the substring of the string
+	Contains interface{} `json:"contains" yaml:"contains"`
+	// Type is one of string, number, boolean, null, array, object
+	Type string `json:"type" yaml:"type"`
+}
+
+// UnmarshalYAML decodes the assertion and tells whether it has the key equals, so `equals: null` is checked
+func (a *ResponseAssertion) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain ResponseAssertion
+	if err := unmarshal((*plain)(a)); err != nil {
+		return err
+	}
+	var keys map[string]interface{}
+	if err := unmarshal(&keys); err != nil {
+		return err
+	}
+	_, a.EqualsSet = keys["equals"]
+	return nil
+}
+
+// RetryParams define how the request is repeated until checks pass
+type RetryParams struct {
+	MaxAttempts int
+	Interval    time.Duration
+	// Backoff multiplies the interval after each attempt
+	Backoff float64
+	// Until lists names of checkers which must pass, all checkers if empty
+	Until []string
+}
+
 type Summary struct {
 	Success bool
 	Failed  int
 	Total   int
+	Skipped int
 }
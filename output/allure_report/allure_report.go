@@ -0,0 +1,202 @@
+package allure_report
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rezikovka/gonkey/models"
+	"github.com/rezikovka/gonkey/output"
+)
+
+const DefaultReportLocation = "allure-results"
+
+type AllureReportOutput struct {
+	output.OutputInterface
+
+	suiteName      string
+	reportLocation string
+}
+
+func NewOutput(suiteName, reportLocation string) (*AllureReportOutput, error) {
+	resultsDir, err := filepath.Abs(reportLocation)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(resultsDir, 0777); err != nil {
+		return nil, err
+	}
+	return &AllureReportOutput{
+		suiteName:      suiteName,
+		reportLocation: resultsDir,
+	}, nil
+}
+
+func (o *AllureReportOutput) Process(t models.TestInterface, result *models.Result) error {
+	start := toMillis(result.StartTime)
+	stop := toMillis(result.StartTime.Add(result.Duration))
+
+	if result.Skipped() {
+		return o.writeResult(&testResult{
+			UUID:          newUUID(),
+			HistoryID:     historyID(o.suiteName, t.GetName()),
+			Name:          t.GetName(),
+			FullName:      o.suiteName + ": " + t.GetName(),
+			Status:        statusSkipped,
+			StatusDetails: statusDetails{Message: result.SkipReason},
+			Stage:         stageFinished,
+			Labels: []label{
+				{Name: "suite", Value: o.suiteName},
+				{Name: "story", Value: t.Path()},
+			},
+			Start: start,
+			Stop:  stop,
+		})
+	}
+
+	res := testResult{
+		UUID:      newUUID(),
+		HistoryID: historyID(o.suiteName, t.GetName()),
+		Name:      t.GetName(),
+		FullName:  o.suiteName + ": " + t.GetName(),
+		Status:    statusPassed,
+		Stage:     stageFinished,
+		Labels: []label{
+			{Name: "suite", Value: o.suiteName},
+			{Name: "story", Value: result.Path},
+		},
+		Start: start,
+		Stop:  stop,
+	}
+
+	url := result.Path
+	if result.Query != "" {
+		url += "?" + result.Query
+	}
+	requestStep, err := o.newStep("Request", start, stop, []namedContent{
+		{"Request", fmt.Sprintf("%s %s\n\n%s", t.GetMethod(), url, result.RequestBody)},
+	})
+	if err != nil {
+		return err
+	}
+	responseStep, err := o.newStep("Response", start, stop, []namedContent{
+		{"Response", fmt.Sprintf("%s\n\n%s", result.ResponseStatus, result.ResponseBody)},
+	})
+	if err != nil {
+		return err
+	}
+	res.Steps = append(res.Steps, requestStep, responseStep)
+
+	if result.DbQuery != "" {
+		dbStep, err := o.newStep("DB query", start, stop, []namedContent{
+			{"DB query", result.DbQuery},
+			{"DB response", strings.Join(result.DbResponse, "\n")},
+		})
+		if err != nil {
+			return err
+		}
+		res.Steps = append(res.Steps, dbStep)
+	}
+
+	if !result.Passed() {
+		errs := make([]string, len(result.Errors))
+		for i, e := range result.Errors {
+			errs[i] = fmt.Sprintf("%d) %s", i+1, e.Error())
+		}
+		res.Status = statusFailed
+		if isBroken(result.Errors) {
+			res.Status = statusBroken
+		}
+		res.StatusDetails = statusDetails{
+			Message: "test has errors",
+			Trace:   output.StripColors(strings.Join(errs, "\n\n")),
+		}
+		errorsAttachment, err := o.writeAttachment("Errors", res.StatusDetails.Trace)
+		if err != nil {
+			return err
+		}
+		res.Attachments = append(res.Attachments, errorsAttachment)
+	}
+
+	return o.writeResult(&res)
+}
+
+type namedContent struct {
+	name    string
+	content string
+}
+
+func (o *AllureReportOutput) newStep(name string, start, stop int64, attachments []namedContent) (step, error) {
+	s := step{
+		Name:   name,
+		Status: statusPassed,
+		Stage:  stageFinished,
+		Start:  start,
+		Stop:   stop,
+	}
+	for _, a := range attachments {
+		att, err := o.writeAttachment(a.name, a.content)
+		if err != nil {
+			return s, err
+		}
+		s.Attachments = append(s.Attachments, att)
+	}
+	return s, nil
+}
+
+func (o *AllureReportOutput) writeAttachment(name, content string) (attachment, error) {
+	source := newUUID() + "-attachment.txt"
+	if err := ioutil.WriteFile(filepath.Join(o.reportLocation, source), []byte(content), 0666); err != nil {
+		return attachment{}, err
+	}
+	return attachment{
+		Name:   name,
+		Source: source,
+		Type:   "text/plain",
+	}, nil
+}
+
+func (o *AllureReportOutput) writeResult(res *testResult) error {
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(o.reportLocation, res.UUID+"-result.json"), data, 0666)
+}
+
+// isBroken returns true if the test could not be executed till the end
+func isBroken(errs []error) bool {
+	for _, e := range errs {
+		switch e.(type) {
+		case *models.ExecutionError, *models.TimeoutError:
+			return true
+		}
+	}
+	return false
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// historyID identifies the test between runs, so Allure can build its history
+func historyID(suiteName, testName string) string {
+	sum := md5.Sum([]byte(suiteName + testName))
+	return hex.EncodeToString(sum[:])
+}
+
+// newUUID generates random UUID v4
+func newUUID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
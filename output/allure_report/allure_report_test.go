@@ -0,0 +1,210 @@
+package allure_report
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rezikovka/gonkey/models"
+	"github.com/rezikovka/gonkey/testloader/yaml_file"
+)
+
+// newTestOutput makes the output into the temporary directory
+func newTestOutput(t *testing.T) (*AllureReportOutput, string) {
+	dir, err := ioutil.TempDir("", "gonkey-allure")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	o, err := NewOutput("Suite", filepath.Join(dir, "results"))
+	require.NoError(t, err)
+	return o, filepath.Join(dir, "results")
+}
+
+// readResults reads written results of tests and their attachments by sources
+func readResults(t *testing.T, dir string) ([]testResult, map[string]string) {
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+
+	var results []testResult
+	attachments := make(map[string]string)
+	for _, f := range files {
+		data, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		require.NoError(t, err)
+		switch {
+		case strings.HasSuffix(f.Name(), "-result.json"):
+			var res testResult
+			require.NoError(t, json.Unmarshal(data, &res))
+			assert.Equal(t, res.UUID+"-result.json", f.Name())
+			results = append(results, res)
+		case strings.HasSuffix(f.Name(), "-attachment.txt"):
+			attachments[f.Name()] = string(data)
+		default:
+			t.Errorf("unexpected file %s", f.Name())
+		}
+	}
+	return results, attachments
+}
+
+// attachmentsOf returns contents of the attachments by names
+func attachmentsOf(t *testing.T, list []attachment, files map[string]string) map[string]string {
+	res := make(map[string]string)
+	for _, a := range list {
+		assert.Equal(t, "text/plain", a.Type)
+		content, ok := files[a.Source]
+		require.True(t, ok, "attachment %s is not written", a.Source)
+		res[a.Name] = content
+	}
+	return res
+}
+
+func newTest(name string) *yaml_file.Test {
+	return &yaml_file.Test{TestDefinition: yaml_file.TestDefinition{Name: name, Method: "POST", RequestURL: "/orders"}}
+}
+
+func TestPassedTest(t *testing.T) {
+	o, dir := newTestOutput(t)
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, o.Process(newTest("create order"), &models.Result{
+		Path:           "/orders",
+		Query:          "dry=1",
+		RequestBody:    `{"id": 1}`,
+		ResponseStatus: "201 Created",
+		ResponseBody:   `{"ok": true}`,
+		StartTime:      start,
+		Duration:       1500 * time.Millisecond,
+	}))
+
+	results, files := readResults(t, dir)
+	require.Len(t, results, 1)
+	res := results[0]
+	assert.Equal(t, "create order", res.Name)
+	assert.Equal(t, "Suite: create order", res.FullName)
+	assert.Equal(t, historyID("Suite", "create order"), res.HistoryID)
+	assert.Equal(t, statusPassed, res.Status)
+	assert.Equal(t, stageFinished, res.Stage)
+	assert.Equal(t, []label{{Name: "suite", Value: "Suite"}, {Name: "story", Value: "/orders"}}, res.Labels)
+	assert.Equal(t, start.UnixNano()/int64(time.Millisecond), res.Start)
+	assert.Equal(t, res.Start+1500, res.Stop)
+	assert.Empty(t, res.Attachments)
+	assert.Empty(t, res.StatusDetails)
+
+	require.Len(t, res.Steps, 2)
+	assert.Equal(t, "Request", res.Steps[0].Name)
+	assert.Equal(t, map[string]string{"Request": "POST /orders?dry=1\n\n{\"id\": 1}"}, attachmentsOf(t, res.Steps[0].Attachments, files))
+	assert.Equal(t, "Response", res.Steps[1].Name)
+	assert.Equal(t, map[string]string{"Response": "201 Created\n\n{\"ok\": true}"}, attachmentsOf(t, res.Steps[1].Attachments, files))
+	for _, s := range res.Steps {
+		assert.Equal(t, statusPassed, s.Status)
+		assert.Equal(t, res.Start, s.Start)
+		assert.Equal(t, res.Stop, s.Stop)
+	}
+}
+
+func TestDbQueryStep(t *testing.T) {
+	o, dir := newTestOutput(t)
+	require.NoError(t, o.Process(newTest("check db"), &models.Result{
+		DbQuery:    "SELECT id FROM orders",
+		DbResponse: []string{`{"id": 1}`, `{"id": 2}`},
+	}))
+
+	results, files := readResults(t, dir)
+	require.Len(t, results, 1)
+	require.Len(t, results[0].Steps, 3)
+	dbStep := results[0].Steps[2]
+	assert.Equal(t, "DB query", dbStep.Name)
+	assert.Equal(t, map[string]string{
+		"DB query":    "SELECT id FROM orders",
+		"DB response": "{\"id\": 1}\n{\"id\": 2}",
+	}, attachmentsOf(t, dbStep.Attachments, files))
+}
+
+func TestFailedTest(t *testing.T) {
+	tests := []struct {
+		name           string
+		errors         []error
+		expectedStatus string
+		expectedTrace  string
+	}{
+		{
+			name:           "failed check",
+			errors:         []error{errors.New("\x1b[32mvalues do not match\x1b[0m"), errors.New("status mismatch")},
+			expectedStatus: statusFailed,
+			expectedTrace:  "1) values do not match\n\n2) status mismatch",
+		},
+		{
+			name:           "execution error",
+			errors:         []error{models.NewExecutionError("request", errors.New("connection refused"))},
+			expectedStatus: statusBroken,
+		},
+		{
+			name:           "timeout",
+			errors:         []error{&models.TimeoutError{Timeout: time.Second}},
+			expectedStatus: statusBroken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, dir := newTestOutput(t)
+			require.NoError(t, o.Process(newTest(tt.name), &models.Result{Errors: tt.errors}))
+
+			results, files := readResults(t, dir)
+			require.Len(t, results, 1)
+			res := results[0]
+			assert.Equal(t, tt.expectedStatus, res.Status)
+			assert.Equal(t, "test has errors", res.StatusDetails.Message)
+			if tt.expectedTrace != "" {
+				assert.Equal(t, tt.expectedTrace, res.StatusDetails.Trace)
+			}
+			assert.Equal(t, map[string]string{"Errors": res.StatusDetails.Trace}, attachmentsOf(t, res.Attachments, files))
+			assert.NotContains(t, res.StatusDetails.Trace, "\x1b[")
+		})
+	}
+}
+
+func TestSkippedTest(t *testing.T) {
+	o, dir := newTestOutput(t)
+	require.NoError(t, o.Process(newTest("skipped"), &models.Result{SkipReason: "not ready"}))
+
+	results, files := readResults(t, dir)
+	require.Len(t, results, 1)
+	res := results[0]
+	assert.Equal(t, statusSkipped, res.Status)
+	assert.Equal(t, "not ready", res.StatusDetails.Message)
+	assert.Equal(t, []label{{Name: "suite", Value: "Suite"}, {Name: "story", Value: "/orders"}}, res.Labels)
+	assert.Empty(t, res.Steps)
+	assert.Empty(t, files)
+}
+
+func TestResultsOfTests(t *testing.T) {
+	o, dir := newTestOutput(t)
+	for _, name := range []string{"first", "second", "first"} {
+		require.NoError(t, o.Process(newTest(name), &models.Result{}))
+	}
+
+	results, _ := readResults(t, dir)
+	require.Len(t, results, 3)
+	uuids := make(map[string]bool)
+	histories := make(map[string]int)
+	for _, res := range results {
+		uuids[res.UUID] = true
+		histories[res.HistoryID]++
+	}
+	// each result is new, but results of the same test share the history
+	assert.Len(t, uuids, 3)
+	assert.Equal(t, map[string]int{historyID("Suite", "first"): 2, historyID("Suite", "second"): 1}, histories)
+}
+
+func TestNewUUID(t *testing.T) {
+	uuid := newUUID()
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, uuid)
+	assert.NotEqual(t, uuid, newUUID())
+}
@@ -0,0 +1,52 @@
+package allure_report
+
+// Allure 2 result format, see https://github.com/allure-framework/allure2-model
+
+const (
+	statusPassed  = "passed"
+	statusFailed  = "failed"
+	statusBroken  = "broken"
+	statusSkipped = "skipped"
+
+	stageFinished = "finished"
+)
+
+type testResult struct {
+	UUID          string        `json:"uuid"`
+	HistoryID     string        `json:"historyId"`
+	Name          string        `json:"name"`
+	FullName      string        `json:"fullName"`
+	Status        string        `json:"status"`
+	StatusDetails statusDetails `json:"statusDetails"`
+	Stage         string        `json:"stage"`
+	Steps         []step        `json:"steps,omitempty"`
+	Attachments   []attachment  `json:"attachments,omitempty"`
+	Labels        []label       `json:"labels"`
+	Start         int64         `json:"start"`
+	Stop          int64         `json:"stop"`
+}
+
+type statusDetails struct {
+	Message string `json:"message,omitempty"`
+	Trace   string `json:"trace,omitempty"`
+}
+
+type step struct {
+	Name        string       `json:"name"`
+	Status      string       `json:"status"`
+	Stage       string       `json:"stage"`
+	Attachments []attachment `json:"attachments,omitempty"`
+	Start       int64        `json:"start"`
+	Stop        int64        `json:"stop"`
+}
+
+type attachment struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Type   string `json:"type"`
+}
+
+type label struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
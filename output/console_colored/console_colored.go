@@ -27,6 +27,15 @@ func NewOutput(verbose bool) *ConsoleColoredOutput {
 }
 
 func (o *ConsoleColoredOutput) Process(t models.TestInterface, result *models.Result) error {
+	if result.Skipped() {
+		o.coloredPrintf(
+			"\n       Name: %s\n     Result: %s %s\n",
+			color.GreenString(t.GetName()),
+			color.New(color.FgHiWhite, color.BgYellow).Sprint("SKIPPED"),
+			result.SkipReason,
+		)
+		return nil
+	}
 	if !result.Passed() || o.verbose {
 		text, err := renderResult(result)
 		if err != nil {
@@ -68,6 +77,9 @@ Request:
 
 Response:
      Status: {{ cyan .ResponseStatus }}
+{{- if gt .Attempts 1 }}
+   Attempts: {{ cyan .Attempts }}
+{{- end }}
        Body:
 {{ if .ResponseBody }}{{ yellow .ResponseBody }}{{ else }}{{ yellow "<no body>" }}{{ end }}
 
@@ -112,4 +124,7 @@ func templateFuncMap() template.FuncMap {
 
 func (o *ConsoleColoredOutput) ShowSummary(summary *models.Summary) {
 	o.coloredPrintf("\nFailed tests: %d/%d\n", summary.Failed, summary.Total)
+	if summary.Skipped > 0 {
+		o.coloredPrintf("Skipped tests: %d\n", summary.Skipped)
+	}
 }
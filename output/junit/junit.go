@@ -0,0 +1,200 @@
+package junit
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rezikovka/gonkey/models"
+	"github.com/rezikovka/gonkey/output"
+)
+
+type testSuites struct {
+	XMLName  xml.Name    `xml:"testsuites"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Suites   []testSuite `xml:"testsuite"`
+}
+
+type testSuite struct {
+	Name      string     `xml:"name,attr"`
+	Tests     int        `xml:"tests,attr"`
+	Failures  int        `xml:"failures,attr"`
+	Skipped   int        `xml:"skipped,attr"`
+	Time      string     `xml:"time,attr"`
+	Timestamp string     `xml:"timestamp,attr"`
+	Cases     []testCase `xml:"testcase"`
+
+	duration time.Duration
+}
+
+type testCase struct {
+	Name      string   `xml:"name,attr"`
+	ClassName string   `xml:"classname,attr"`
+	Time      string   `xml:"time,attr"`
+	Failure   *failure `xml:"failure,omitempty"`
+	Skipped   *skipped `xml:"skipped,omitempty"`
+	SystemOut *cdata   `xml:"system-out,omitempty"`
+}
+
+type failure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",cdata"`
+}
+
+type skipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type cdata struct {
+	Text string `xml:",cdata"`
+}
+
+type JUnitOutput struct {
+	output.OutputInterface
+
+	fileName string
+	suites   []*testSuite
+	// suites indexed by the source file of their tests
+	suitesByFile map[string]*testSuite
+}
+
+func NewOutput(fileName string) *JUnitOutput {
+	return &JUnitOutput{
+		fileName:     fileName,
+		suitesByFile: make(map[string]*testSuite),
+	}
+}
+
+func (o *JUnitOutput) Process(t models.TestInterface, result *models.Result) error {
+	suite, ok := o.suitesByFile[t.GetFileName()]
+	if !ok {
+		suite = &testSuite{
+			Name:      suiteName(t.GetFileName()),
+			Timestamp: result.StartTime.Format("2006-01-02T15:04:05"),
+		}
+		o.suitesByFile[t.GetFileName()] = suite
+		o.suites = append(o.suites, suite)
+	}
+
+	tc := testCase{
+		Name:      t.GetName(),
+		ClassName: suite.Name,
+		Time:      formatDuration(result.Duration),
+		SystemOut: &cdata{renderSystemOut(t, result)},
+	}
+	if result.Skipped() {
+		tc.SystemOut = nil
+		tc.Skipped = &skipped{Message: result.SkipReason}
+		suite.Skipped++
+	}
+	if !result.Passed() {
+		tc.Failure = &failure{
+			Message: fmt.Sprintf("test has %d error(s)", len(result.Errors)),
+			Text:    renderErrors(result.Errors),
+		}
+		suite.Failures++
+	}
+
+	suite.Tests++
+	suite.duration += result.Duration
+	suite.Cases = append(suite.Cases, tc)
+
+	return nil
+}
+
+// Finalize writes the report to the file
+func (o *JUnitOutput) Finalize(summary *models.Summary) error {
+	report := testSuites{
+		Name:     "gonkey",
+		Tests:    summary.Total,
+		Failures: summary.Failed,
+	}
+	for _, s := range o.suites {
+		// skipped tests are not counted in the summary total
+		report.Tests += s.Skipped
+		report.Skipped += s.Skipped
+	}
+	var total time.Duration
+	for _, s := range o.suites {
+		s.Time = formatDuration(s.duration)
+		total += s.duration
+		report.Suites = append(report.Suites, *s)
+	}
+	report.Time = formatDuration(total)
+
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(o.fileName); dir != "" {
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			return err
+		}
+	}
+	return ioutil.WriteFile(o.fileName, append([]byte(xml.Header), data...), 0666)
+}
+
+// suiteName makes suite name from the test file path relative to the working directory
+func suiteName(fileName string) string {
+	if fileName == "" {
+		return "gonkey"
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, fileName); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return fileName
+}
+
+func renderErrors(errs []error) string {
+	rendered := make([]string, len(errs))
+	for i, e := range errs {
+		rendered[i] = fmt.Sprintf("%d) %s", i+1, e.Error())
+	}
+	return output.StripColors(strings.Join(rendered, "\n\n"))
+}
+
+func renderSystemOut(t models.TestInterface, result *models.Result) string {
+	var b strings.Builder
+	if len(result.Steps) == 0 {
+		renderExchange(&b, t, result)
+		return b.String()
+	}
+	// each step of the scenario sends its own request
+	for i, step := range result.Steps {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "Step %d (%s):\n", i+1, step.Test.GetName())
+		renderExchange(&b, step.Test, step)
+	}
+	return b.String()
+}
+
+func renderExchange(b *strings.Builder, t models.TestInterface, result *models.Result) {
+	fmt.Fprintf(b, "Request:\n%s %s%s\n", t.GetMethod(), t.Path(), t.ToQuery())
+	if result.RequestBody != "" {
+		fmt.Fprintf(b, "%s\n", result.RequestBody)
+	}
+	fmt.Fprintf(b, "\nResponse:\n%s\n", result.ResponseStatus)
+	if result.ResponseBody != "" {
+		fmt.Fprintf(b, "%s\n", result.ResponseBody)
+	}
+	if result.DbQuery != "" {
+		fmt.Fprintf(b, "\nDb Request:\n%s\nDb Response:\n%s\n", result.DbQuery, strings.Join(result.DbResponse, "\n"))
+	}
+}
+
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,162 @@
+package junit
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rezikovka/gonkey/models"
+	"github.com/rezikovka/gonkey/testloader/yaml_file"
+)
+
+func newTest(fileName, name, method, path, query string) *yaml_file.Test {
+	return &yaml_file.Test{
+		TestDefinition: yaml_file.TestDefinition{Name: name, Method: method, RequestURL: path, QueryParams: query},
+		FileName:       fileName,
+	}
+}
+
+// writeReport processes the results of the tests and returns the written report
+func writeReport(t *testing.T, summary *models.Summary, process func(o *JUnitOutput)) string {
+	dir, err := ioutil.TempDir("", "gonkey-junit")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	fileName := filepath.Join(dir, "reports", "junit.xml")
+	o := NewOutput(fileName)
+	process(o)
+	require.NoError(t, o.Finalize(summary))
+
+	data, err := ioutil.ReadFile(fileName)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestReport(t *testing.T) {
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	report := writeReport(t, &models.Summary{Failed: 1, Total: 3, Skipped: 1}, func(o *JUnitOutput) {
+		require.NoError(t, o.Process(newTest("orders.yaml", "create order", "POST", "/orders", "?dry=1"), &models.Result{
+			RequestBody:    `{"id": 1}`,
+			ResponseStatus: "201 Created",
+			ResponseBody:   `{"ok": true}`,
+			StartTime:      start,
+			Duration:       1500 * time.Millisecond,
+		}))
+		require.NoError(t, o.Process(newTest("orders.yaml", "get order", "GET", "/orders/1", ""), &models.Result{
+			ResponseStatus: "404 Not Found",
+			DbQuery:        "SELECT id FROM orders",
+			DbResponse:     []string{`{"id": 1}`, `{"id": 2}`},
+			Errors:         []error{errors.New("\x1b[31mstatus mismatch\x1b[0m"), errors.New("values do not match")},
+			StartTime:      start,
+			Duration:       250 * time.Millisecond,
+		}))
+		require.NoError(t, o.Process(newTest("orders.yaml", "delete order", "DELETE", "/orders/1", ""), &models.Result{
+			SkipReason: "not ready",
+			StartTime:  start,
+		}))
+		require.NoError(t, o.Process(newTest("users.yaml", "list users", "GET", "/users", ""), &models.Result{
+			ResponseStatus: "200 OK",
+			StartTime:      start.Add(time.Second),
+			Duration:       time.Second,
+		}))
+	})
+
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="gonkey" tests="4" failures="1" skipped="1" time="2.750">
+  <testsuite name="orders.yaml" tests="3" failures="1" skipped="1" time="1.750" timestamp="2020-01-02T03:04:05">
+    <testcase name="create order" classname="orders.yaml" time="1.500">
+      <system-out><![CDATA[Request:
+POST /orders?dry=1
+{"id": 1}
+
+Response:
+201 Created
+{"ok": true}
+]]></system-out>
+    </testcase>
+    <testcase name="get order" classname="orders.yaml" time="0.250">
+      <failure message="test has 2 error(s)"><![CDATA[1) status mismatch
+
+2) values do not match]]></failure>
+      <system-out><![CDATA[Request:
+GET /orders/1
+
+Response:
+404 Not Found
+
+Db Request:
+SELECT id FROM orders
+Db Response:
+{"id": 1}
+{"id": 2}
+]]></system-out>
+    </testcase>
+    <testcase name="delete order" classname="orders.yaml" time="0.000">
+      <skipped message="not ready"></skipped>
+    </testcase>
+  </testsuite>
+  <testsuite name="users.yaml" tests="1" failures="0" skipped="0" time="1.000" timestamp="2020-01-02T03:04:06">
+    <testcase name="list users" classname="users.yaml" time="1.000">
+      <system-out><![CDATA[Request:
+GET /users
+
+Response:
+200 OK
+]]></system-out>
+    </testcase>
+  </testsuite>
+</testsuites>`, report)
+}
+
+func TestReportOfScenario(t *testing.T) {
+	scenario := newTest("orders.yaml", "order lifecycle", "", "", "")
+	create := newTest("orders.yaml", "create", "POST", "/orders", "")
+	pay := newTest("orders.yaml", "pay", "POST", "/orders/1/pay", "?method=card")
+	steps := []*models.Result{
+		{Test: create, RequestBody: `{"id": 1}`, ResponseStatus: "201 Created"},
+		{Test: pay, ResponseStatus: "402 Payment Required", ResponseBody: `{"error": "declined"}`, Errors: []error{errors.New("status mismatch")}},
+	}
+	result := *steps[1]
+	result.Steps = steps
+	result.Duration = 2 * time.Second
+
+	report := writeReport(t, &models.Summary{Failed: 1, Total: 1}, func(o *JUnitOutput) {
+		require.NoError(t, o.Process(scenario, &result))
+	})
+
+	assert.Contains(t, report, `<testcase name="order lifecycle" classname="orders.yaml" time="2.000">
+      <failure message="test has 1 error(s)"><![CDATA[1) status mismatch]]></failure>
+      <system-out><![CDATA[Step 1 (create):
+Request:
+POST /orders
+{"id": 1}
+
+Response:
+201 Created
+
+Step 2 (pay):
+Request:
+POST /orders/1/pay?method=card
+
+Response:
+402 Payment Required
+{"error": "declined"}
+]]></system-out>
+    </testcase>`)
+}
+
+func TestSuiteName(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+
+	assert.Equal(t, "gonkey", suiteName(""))
+	assert.Equal(t, filepath.Join("cases", "orders.yaml"), suiteName(filepath.Join(wd, "cases", "orders.yaml")))
+	outside := filepath.Join(filepath.Dir(wd), "orders.yaml")
+	assert.Equal(t, outside, suiteName(outside))
+}
@@ -1,9 +1,24 @@
 package output
 
 import (
+	"regexp"
+
 	"github.com/rezikovka/gonkey/models"
 )
 
 type OutputInterface interface {
 	Process(models.TestInterface, *models.Result) error
 }
+
+// FinalizerInterface is implemented by outputs which write
+// the report once all the tests have been processed
+type FinalizerInterface interface {
+	Finalize(*models.Summary) error
+}
+
+var colorsRx = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// StripColors removes terminal color codes which are used in error messages
+func StripColors(s string) string {
+	return colorsRx.ReplaceAllString(s, "")
+}
@@ -21,6 +21,13 @@ func NewOutput(t *testing.T) *TestingOutput {
 }
 
 func (o *TestingOutput) Process(t models.TestInterface, result *models.Result) error {
+	if result.Skipped() {
+		// report the skipped test as a separate subtest, so the reason is shown by go test
+		o.testing.Run(t.GetName(), func(t *testing.T) {
+			t.Skip(result.SkipReason)
+		})
+		return nil
+	}
 	if !result.Passed() {
 		text, err := renderResult(result)
 		if err != nil {
@@ -56,6 +63,9 @@ Request:
 
 Response:
      Status: {{ .ResponseStatus }}
+{{- if gt .Attempts 1 }}
+   Attempts: {{ .Attempts }}
+{{- end }}
        Body:
 {{ if .ResponseBody }}{{ .ResponseBody }}{{ else }}{{ "<no body>" }}{{ end }}
 
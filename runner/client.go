@@ -0,0 +1,150 @@
+package runner
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// maxRedirects is the number of redirects followed by tests with followRedirects
+const maxRedirects = 10
+
+// ClientConfig configures the default client
+type ClientConfig struct {
+	InsecureSkipVerify bool   // don't verify the server certificate
+	CAFile             string // PEM file with CA certificates to verify the server, system ones are used if empty
+	CertFile           string // PEM file with the client certificate for mutual TLS
+	KeyFile            string // PEM file with the key of the client certificate
+	ServerName         string // name sent by SNI and verified in the server certificate, the host of the request by default
+	Proxy              string // proxy URL, HTTP_PROXY, HTTPS_PROXY and NO_PROXY env vars are used if empty
+	NoProxy            string // comma-separated hosts which are accessed without the proxy
+	HTTP2              bool   // try HTTP/2 for TLS connections
+}
+
+// defaultClientConfig keeps the behaviour of the client before it became configurable
+var defaultClientConfig = &ClientConfig{InsecureSkipVerify: true}
+
+// followRedirectsKey is the context key which allows the client to follow redirects of the request
+type followRedirectsKey struct{}
+
+// newClient makes the client which sends requests by the given transport,
+// the transport is made by the config if it's nil
+func newClient(transport http.RoundTripper, config *ClientConfig) (*http.Client, error) {
+	if transport == nil {
+		if config == nil {
+			config = defaultClientConfig
+		}
+		var err error
+		transport, err = newTransport(config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if follow, _ := req.Context().Value(followRedirectsKey{}).(bool); !follow {
+				return http.ErrUseLastResponse
+			}
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return nil
+		},
+	}, nil
+}
+
+func newTransport(config *ClientConfig) (*http.Transport, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.InsecureSkipVerify,
+		ServerName:         config.ServerName,
+	}
+
+	if config.CAFile != "" {
+		data, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA file: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.CertFile != "" || config.KeyFile != "" {
+		if config.CertFile == "" || config.KeyFile == "" {
+			return nil, errors.New("both client certificate and key files must be set")
+		}
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	proxyConfig := httpproxy.FromEnvironment()
+	if config.Proxy != "" {
+		if _, err := url.Parse(config.Proxy); err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %s", err)
+		}
+		proxyConfig.HTTPProxy = config.Proxy
+		proxyConfig.HTTPSProxy = config.Proxy
+	}
+	if config.NoProxy != "" {
+		proxyConfig.NoProxy = config.NoProxy
+	}
+	proxyFunc := proxyConfig.ProxyFunc()
+
+	return &http.Transport{
+		TLSClientConfig:   tlsConfig,
+		ForceAttemptHTTP2: config.HTTP2,
+		Proxy: func(req *http.Request) (*url.URL, error) {
+			// the proxy function never proxies loopback hosts, but local services
+			// are sent through the proxy like others unless they are listed in NoProxy
+			if isLoopback(req.URL.Hostname()) && !listedHost(proxyConfig.NoProxy, req.URL) {
+				u := *req.URL
+				u.Host = loopbackPlaceholder
+				return proxyFunc(&u)
+			}
+			return proxyFunc(req.URL)
+		},
+	}, nil
+}
+
+// loopbackPlaceholder replaces loopback hosts to get the proxy of them
+const loopbackPlaceholder = "loopback.invalid"
+
+func isLoopback(host string) bool {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// listedHost tells whether the comma-separated list contains the host or host:port of the URL or *
+func listedHost(list string, u *url.URL) bool {
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "*" || entry == u.Hostname() || entry == u.Host {
+			return true
+		}
+	}
+	return false
+}
+
+// withRedirects allows the client to follow redirects of requests made with the context
+func withRedirects(ctx context.Context) context.Context {
+	return context.WithValue(ctx, followRedirectsKey{}, true)
+}
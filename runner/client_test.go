@@ -0,0 +1,113 @@
+package runner
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		config   ClientConfig
+		target   string
+		expected string
+	}{
+		{
+			name:     "configured proxy of localhost",
+			config:   ClientConfig{Proxy: "http://proxy:3128"},
+			target:   "http://localhost:8080/orders",
+			expected: "http://proxy:3128",
+		},
+		{
+			name:     "configured proxy of loopback IP",
+			config:   ClientConfig{Proxy: "http://proxy:3128"},
+			target:   "https://127.0.0.1:8443/orders",
+			expected: "http://proxy:3128",
+		},
+		{
+			name:     "configured proxy of remote host",
+			config:   ClientConfig{Proxy: "http://proxy:3128"},
+			target:   "http://service/orders",
+			expected: "http://proxy:3128",
+		},
+		{
+			name:   "localhost without proxy",
+			config: ClientConfig{Proxy: "http://proxy:3128", NoProxy: "service, localhost"},
+			target: "http://localhost:8080/orders",
+		},
+		{
+			name:   "localhost port without proxy",
+			config: ClientConfig{Proxy: "http://proxy:3128", NoProxy: "localhost:8080"},
+			target: "http://localhost:8080/orders",
+		},
+		{
+			name:   "remote host without proxy",
+			config: ClientConfig{Proxy: "http://proxy:3128", NoProxy: "service"},
+			target: "http://service/orders",
+		},
+		{
+			name:     "HTTP_PROXY of localhost",
+			env:      map[string]string{"HTTP_PROXY": "http://debug:8888"},
+			target:   "http://localhost:8080/orders",
+			expected: "http://debug:8888",
+		},
+		{
+			name:   "NO_PROXY of localhost",
+			env:    map[string]string{"HTTP_PROXY": "http://debug:8888", "NO_PROXY": "localhost"},
+			target: "http://localhost:8080/orders",
+		},
+		{
+			name:   "no proxy",
+			target: "http://localhost:8080/orders",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := setProxyEnv(tt.env)
+			defer restore()
+
+			transport, err := newTransport(&tt.config)
+			require.NoError(t, err)
+			req, err := http.NewRequest(http.MethodGet, tt.target, nil)
+			require.NoError(t, err)
+
+			proxy, err := transport.Proxy(req)
+			require.NoError(t, err)
+			if tt.expected == "" {
+				assert.Nil(t, proxy)
+			} else {
+				require.NotNil(t, proxy)
+				assert.Equal(t, tt.expected, proxy.String())
+			}
+		})
+	}
+}
+
+// setProxyEnv replaces proxy env vars by the given ones and returns the function which restores them
+func setProxyEnv(env map[string]string) func() {
+	names := []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy", "REQUEST_METHOD"}
+	saved := make(map[string]string)
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok {
+			saved[name] = value
+		}
+		_ = os.Unsetenv(name)
+	}
+	for name, value := range env {
+		_ = os.Setenv(name, value)
+	}
+	return func() {
+		for _, name := range names {
+			_ = os.Unsetenv(name)
+			if value, ok := saved[name]; ok {
+				_ = os.Setenv(name, value)
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package runner
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"sync"
+
+	"github.com/rezikovka/gonkey/models"
+)
+
+// cookieJars holds cookie jars shared by the tests of the same file
+type cookieJars struct {
+	sync.Mutex
+	byFile map[string]http.CookieJar
+}
+
+func newCookieJars() *cookieJars {
+	return &cookieJars{
+		byFile: make(map[string]http.CookieJar),
+	}
+}
+
+// clientFor returns the client which keeps cookies according to the cookie jar mode of the test
+func (j *cookieJars) clientFor(v models.TestInterface, client *http.Client) *http.Client {
+	var jar http.CookieJar
+	switch v.CookieJar() {
+	case models.CookieJarFile:
+		jar = j.fileJar(v.GetFileName())
+	case models.CookieJarScenario:
+		jar = newCookieJar()
+	default:
+		return client
+	}
+
+	c := *client
+	c.Jar = jar
+	return &c
+}
+
+func (j *cookieJars) fileJar(fileName string) http.CookieJar {
+	j.Lock()
+	defer j.Unlock()
+
+	jar, ok := j.byFile[fileName]
+	if !ok {
+		jar = newCookieJar()
+		j.byFile[fileName] = jar
+	}
+	return jar
+}
+
+func newCookieJar() http.CookieJar {
+	// the error is returned only for invalid options
+	jar, _ := cookiejar.New(nil)
+	return jar
+}
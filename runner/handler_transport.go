@@ -0,0 +1,46 @@
+package runner
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+)
+
+// handlerTransport passes requests directly to the handler without network
+type handlerTransport struct {
+	handler http.Handler
+}
+
+// NewHandlerTransport makes the transport which serves requests by the handler in-process
+func NewHandlerTransport(handler http.Handler) http.RoundTripper {
+	return &handlerTransport{
+		handler: handler,
+	}
+}
+
+func (t *handlerTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	// make the request look like the one received by a server
+	serverReq := req.Clone(req.Context())
+	if serverReq.Body == nil {
+		serverReq.Body = http.NoBody
+	}
+	serverReq.RequestURI = req.URL.RequestURI()
+	serverReq.RemoteAddr = "127.0.0.1:0"
+	if serverReq.Host == "" {
+		serverReq.Host = req.URL.Host
+	}
+
+	// the server recovers panics of handlers, so do the same
+	defer func() {
+		if p := recover(); p != nil {
+			resp, err = nil, fmt.Errorf("handler panicked: %v", p)
+		}
+	}()
+
+	recorder := httptest.NewRecorder()
+	t.handler.ServeHTTP(recorder, serverReq)
+
+	resp = recorder.Result()
+	resp.Request = req
+	return resp, nil
+}
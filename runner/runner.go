@@ -1,14 +1,19 @@
 package runner
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/rezikovka/gonkey/checker"
 	"github.com/rezikovka/gonkey/fixtures"
+	"github.com/rezikovka/gonkey/mocks"
 	"github.com/rezikovka/gonkey/models"
 	"github.com/rezikovka/gonkey/output"
 	"github.com/rezikovka/gonkey/testloader"
@@ -16,15 +21,45 @@ import (
 )
 
 type Config struct {
-	Host           string
-	FixturesLoader fixtures.Loader
-	Variables      *variables.Variables
+	Host            string
+	Client          *http.Client      // client which sends requests, by default redirects are not followed
+	Transport       http.RoundTripper // transport of the default client, ignored if the client is set
+	ClientConfig    *ClientConfig     // configuration of the default transport, ignored if the transport is set
+	FixturesLoader  fixtures.Loader
+	Mocks           *mocks.Mocks
+	MocksLoader     *mocks.Loader
+	Variables       *variables.Variables
+	Concurrency     int            // number of test files executed at the same time
+	FailFast        bool           // stop the run on the first test which could not be executed
+	Timeout         time.Duration  // time limit of the whole run
+	TestTimeout     time.Duration  // time limit of a test which doesn't define its own timeout
+	Tags            []string       // run only tests having any of the tags
+	SkipTags        []string       // skip tests having any of the tags
+	NamePattern     *regexp.Regexp // run only tests which names match the pattern
+	UpdateSnapshots bool           // overwrite snapshots of responses instead of comparing with them
 }
 
+// TestWrapper wraps the execution of each test, e.g. to run it as a Go subtest.
+// The wrapper calls run at most once, the test is counted as skipped if run isn't called.
+// run returns the result of the test or nil if the run has been stopped.
+type TestWrapper func(v models.TestInterface, run func() *models.Result)
+
+// ParallelTestWrapper wraps tests which may run at the same time as others, e.g. to run them
+// as parallel Go subtests. It's like TestWrapper, but it may return before run is called,
+// then it returns true and run is called later from another goroutine.
+type ParallelTestWrapper func(v models.TestInterface, run func() *models.Result) (later bool)
+
 type Runner struct {
 	loader   testloader.LoaderInterface
 	output   []output.OutputInterface
 	checkers []checker.CheckerInterface
+	wrapper  TestWrapper
+
+	parallelWrapper ParallelTestWrapper
+	// dispatched is called when all tests are passed to wrappers
+	dispatched func()
+
+	cookieJars *cookieJars
 
 	config *Config
 }
@@ -44,7 +79,25 @@ func (r *Runner) AddCheckers(c ...checker.CheckerInterface) {
 	r.checkers = append(r.checkers, c...)
 }
 
+func (r *Runner) SetTestWrapper(w TestWrapper) {
+	r.wrapper = w
+}
+
+// SetParallelTestWrapper sets the wrapper of tests which may run at the same time as others,
+// the run is limited by the wrapper instead of Concurrency. The run waits for tests called
+// later by the wrapper after dispatched is called, when all tests are passed to wrappers.
+func (r *Runner) SetParallelTestWrapper(w ParallelTestWrapper, dispatched func()) {
+	r.parallelWrapper = w
+	r.dispatched = dispatched
+}
+
 func (r *Runner) Run() (*models.Summary, error) {
+	return r.RunContext(context.Background())
+}
+
+// RunContext runs the tests until the context is done.
+// If the run is interrupted, the summary of the executed tests is returned along with the error.
+func (r *Runner) RunContext(ctx context.Context) (*models.Summary, error) {
 	if r.loader == nil {
 		s := &models.Summary{
 			Success: true,
@@ -59,67 +112,542 @@ func (r *Runner) Run() (*models.Summary, error) {
 		return nil, err
 	}
 
-	client, err := newClient()
-	if err != nil {
-		return nil, err
+	client := r.config.Client
+	if client == nil {
+		client, err = newClient(r.config.Transport, r.config.ClientConfig)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	totalTests := 0
-	failedTests := 0
+	if r.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.config.Timeout)
+		defer cancel()
+	}
 
+	var tests []models.TestInterface
 	for v := range loader {
-		testResult, err := r.executeTest(v, client)
-		if err != nil {
-			// todo: populate error with test name. Currently it is not possible here to get test name.
-			return nil, err
+		tests = append(tests, v)
+	}
+
+	run := &testsRun{focused: hasFocusedTests(tests)}
+	r.cookieJars = newCookieJars()
+
+	isolated := isolatedLoader(r.config.FixturesLoader)
+	// tests isolated from each other can't share the database
+	switch {
+	case r.parallelWrapper != nil && isolated == nil:
+		r.runWrappedParallel(ctx, groupTests(tests), client, run)
+	case r.config.Concurrency > 1 && isolated == nil:
+		r.runParallel(ctx, groupTests(tests), client, run)
+	default:
+		for _, v := range tests {
+			if ctx.Err() != nil || !r.runTest(ctx, v, client, run) {
+				break
+			}
 		}
-		totalTests++
-		if len(testResult.Errors) > 0 {
-			failedTests++
+	}
+	if r.dispatched != nil {
+		r.dispatched()
+	}
+	run.later.Wait()
+
+	if isolated != nil {
+		if err := isolated.Close(); err != nil && run.err == nil {
+			run.err = fmt.Errorf("unable to end DB isolation: %s", err)
 		}
-		for _, o := range r.output {
-			if err := o.Process(v, testResult); err != nil {
+	}
+
+	if run.err != nil {
+		return nil, run.err
+	}
+
+	s := &models.Summary{
+		Success: run.failed == 0,
+		Failed:  run.failed,
+		Total:   run.total,
+		Skipped: run.skipped,
+	}
+
+	for _, o := range r.output {
+		if f, ok := o.(output.FinalizerInterface); ok {
+			if err := f.Finalize(s); err != nil {
 				return nil, err
 			}
 		}
 	}
 
-	s := &models.Summary{
-		Success: failedTests == 0,
-		Failed:  failedTests,
-		Total:   totalTests,
+	if err := ctx.Err(); err != nil {
+		return s, fmt.Errorf("run interrupted: %s", err)
 	}
 
 	return s, nil
 }
 
-func (r *Runner) executeTest(v models.TestInterface, client *http.Client) (*models.Result, error) {
+// testsRun holds the state of the run shared between workers
+type testsRun struct {
+	sync.Mutex
+	total   int
+	failed  int
+	skipped int
+	err     error
+	// only focused tests are run if any
+	focused bool
+	// tests called later by the parallel wrapper
+	later sync.WaitGroup
+}
+
+func (run *testsRun) stopped() bool {
+	run.Lock()
+	defer run.Unlock()
+	return run.err != nil
+}
+
+// selected returns true if the test fits the tags and the name pattern of the run,
+// only focused tests are selected if the run has any
+func (r *Runner) selected(v models.TestInterface, focused bool) bool {
+	if focused && !v.Only() {
+		return false
+	}
+	if len(r.config.Tags) > 0 && !hasAnyTag(v, r.config.Tags) {
+		return false
+	}
+	if hasAnyTag(v, r.config.SkipTags) {
+		return false
+	}
+	if r.config.NamePattern != nil && !r.config.NamePattern.MatchString(v.GetName()) {
+		return false
+	}
+	return true
+}
+
+func hasFocusedTests(tests []models.TestInterface) bool {
+	for _, v := range tests {
+		if v.Only() {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyTag(v models.TestInterface, tags []string) bool {
+	for _, tag := range v.Tags() {
+		for _, t := range tags {
+			if tag == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// testGroup is a sequence of tests from the same file
+type testGroup struct {
+	tests    []models.TestInterface
+	parallel bool
+}
+
+// groupTests groups tests by their files keeping the order of tests.
+// The group may run concurrently with others only if all its tests allow it
+// and don't use mocks, which are shared between all the tests.
+func groupTests(tests []models.TestInterface) []*testGroup {
+	var groups []*testGroup
+	groupsByFile := make(map[string]*testGroup)
+	for _, v := range tests {
+		g, ok := groupsByFile[v.GetFileName()]
+		if !ok {
+			g = &testGroup{parallel: true}
+			groupsByFile[v.GetFileName()] = g
+			groups = append(groups, g)
+		}
+		g.tests = append(g.tests, v)
+		if !v.Parallel() || usesMocks(v) {
+			g.parallel = false
+		}
+	}
+	return groups
+}
+
+func usesMocks(v models.TestInterface) bool {
+	if v.ServiceMocks() != nil {
+		return true
+	}
+	for _, step := range v.Steps() {
+		if step.ServiceMocks() != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// runParallel executes groups of tests by the pool of workers,
+// groups which don't allow parallel execution are run exclusively
+func (r *Runner) runParallel(ctx context.Context, groups []*testGroup, client *http.Client, run *testsRun) {
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, r.config.Concurrency)
+
+	runGroup := func(ctx context.Context, g *testGroup) {
+		for _, v := range g.tests {
+			if ctx.Err() != nil || !r.runTest(ctx, v, client, run) {
+				return
+			}
+		}
+	}
+
+	for _, g := range groups {
+		if run.stopped() || ctx.Err() != nil {
+			break
+		}
+		if !g.parallel {
+			// wait for running groups to finish
+			wg.Wait()
+			runGroup(ctx, g)
+			continue
+		}
+		workers <- struct{}{}
+		wg.Add(1)
+		go func(g *testGroup) {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			runGroup(withParallel(ctx), g)
+		}(g)
+	}
+
+	wg.Wait()
+}
+
+// runWrappedParallel passes tests of groups which allow parallel execution to the parallel wrapper,
+// tests of other groups are run at once
+func (r *Runner) runWrappedParallel(ctx context.Context, groups []*testGroup, client *http.Client, run *testsRun) {
+	for _, g := range groups {
+		for _, v := range g.tests {
+			if run.stopped() || ctx.Err() != nil {
+				return
+			}
+			if !g.parallel {
+				if !r.runTest(ctx, v, client, run) {
+					return
+				}
+				continue
+			}
+			r.runTestLater(withParallel(ctx), v, client, run)
+		}
+	}
+}
+
+// runTestLater passes the test to the parallel wrapper, which may execute it after returning
+func (r *Runner) runTestLater(ctx context.Context, v models.TestInterface, client *http.Client, run *testsRun) {
+	if !r.selected(v, run.focused) {
+		run.Lock()
+		run.skipped++
+		run.Unlock()
+		return
+	}
+
+	run.later.Add(1)
+	called := false
+	later := r.parallelWrapper(v, func() *models.Result {
+		called = true
+		defer run.later.Done()
+		result, _ := r.processTest(ctx, v, client, run)
+		return result
+	})
+	if !later && !called {
+		// the wrapper has filtered out the test
+		run.later.Done()
+		run.Lock()
+		run.skipped++
+		run.Unlock()
+	}
+}
+
+// parallelKey marks the context of tests which run concurrently with others
+type parallelKey struct{}
+
+func withParallel(ctx context.Context) context.Context {
+	return context.WithValue(ctx, parallelKey{}, true)
+}
+
+// sharesMocks returns true if the test may use the mocks, concurrent tests don't declare mocks
+// and must not reset or check the mocks of the test running at the same time
+func (r *Runner) sharesMocks(ctx context.Context) bool {
+	return r.config.Mocks != nil && ctx.Value(parallelKey{}) == nil
+}
+
+// runTest executes the test by the test wrapper if it's set,
+// it returns false if the run must be stopped
+func (r *Runner) runTest(ctx context.Context, v models.TestInterface, client *http.Client, run *testsRun) bool {
+	if !r.selected(v, run.focused) {
+		run.Lock()
+		run.skipped++
+		run.Unlock()
+		return true
+	}
+
+	if r.wrapper == nil {
+		_, ok := r.processTest(ctx, v, client, run)
+		return ok
+	}
+
+	called, ok := false, false
+	r.wrapper(v, func() *models.Result {
+		called = true
+		var result *models.Result
+		result, ok = r.processTest(ctx, v, client, run)
+		return result
+	})
+	if !called {
+		// the wrapper has filtered out the test
+		run.Lock()
+		run.skipped++
+		run.Unlock()
+		return true
+	}
+	return ok
+}
+
+// processTest executes the test and passes its result to outputs one at a time,
+// it returns false if the run must be stopped
+func (r *Runner) processTest(ctx context.Context, v models.TestInterface, client *http.Client, run *testsRun) (*models.Result, bool) {
+	if reason := v.SkipReason(); reason != "" {
+		run.Lock()
+		defer run.Unlock()
+		if run.err != nil {
+			return nil, false
+		}
+		run.skipped++
+		testResult := &models.Result{Test: v, StartTime: time.Now(), SkipReason: reason}
+		return testResult, r.processResult(v, testResult, run)
+	}
+
+	startTime := time.Now()
+	testResult, err := r.executeTest(ctx, v, client)
+
+	run.Lock()
+	defer run.Unlock()
+
+	if run.err != nil {
+		return nil, false
+	}
+	if err != nil {
+		if r.config.FailFast {
+			run.err = fmt.Errorf("test %s: %s", v.GetName(), err)
+			return nil, false
+		}
+		// the test could not be completed, report it as failed and go on
+		if testResult == nil {
+			testResult = &models.Result{
+				Test:      v,
+				StartTime: startTime,
+				Duration:  time.Since(startTime),
+			}
+		}
+		testResult.Errors = append(testResult.Errors, err)
+	}
+	run.total++
+	if len(testResult.Errors) > 0 {
+		run.failed++
+	}
+	return testResult, r.processResult(v, testResult, run)
+}
+
+// processResult passes the result to outputs, the run must be locked
+func (r *Runner) processResult(v models.TestInterface, result *models.Result, run *testsRun) bool {
+	for _, o := range r.output {
+		if err := o.Process(v, result); err != nil {
+			run.err = err
+			return false
+		}
+	}
+	return true
+}
+
+// executeTest runs the test within its time limit and returns its result,
+// on error the result is returned only if the request has been completed
+func (r *Runner) executeTest(ctx context.Context, v models.TestInterface, client *http.Client) (*models.Result, error) {
+	timeout := v.Timeout()
+	if timeout == 0 {
+		timeout = r.config.TestTimeout
+	}
+
+	testCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		testCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	result, err := r.doTest(testCtx, v, client)
+	if err != nil && testCtx.Err() != nil {
+		// the error is caused by the expired context, report it as timeout
+		stage := ""
+		if execErr, ok := err.(*models.ExecutionError); ok {
+			stage = execErr.Stage
+		}
+		timeoutErr := &models.TimeoutError{
+			Stage: stage,
+			Err:   testCtx.Err(),
+		}
+		if ctx.Err() == nil {
+			timeoutErr.Timeout = timeout
+		}
+		err = timeoutErr
+	}
+	return result, err
+}
 
+func (r *Runner) doTest(ctx context.Context, v models.TestInterface, client *http.Client) (result *models.Result, err error) {
 	r.config.Variables.Load(v.GetVariables())
 	v = r.config.Variables.Apply(v)
 
+	// isolate the test from changes made by other tests
+	if isolated := isolatedLoader(r.config.FixturesLoader); isolated != nil {
+		if err := isolated.BeginTest(ctx); err != nil {
+			return nil, models.NewExecutionError(models.StageFixtures, fmt.Errorf("unable to isolate DB: %s", err))
+		}
+		defer func() {
+			if endErr := isolated.EndTest(); endErr != nil && err == nil {
+				err = models.NewExecutionError(models.StageFixtures, fmt.Errorf("unable to discard DB changes: %s", endErr))
+			}
+		}()
+	}
+
 	// load fixtures
 	if r.config.FixturesLoader != nil && v.Fixtures() != nil {
-		if err := r.config.FixturesLoader.Load(v.Fixtures()); err != nil {
-			return nil, fmt.Errorf("unable to load fixtures [%s], error:\n%s", strings.Join(v.Fixtures(), ", "), err)
+		if err := loadFixtures(ctx, r.config.FixturesLoader, v.Fixtures()); err != nil {
+			return nil, models.NewExecutionError(
+				models.StageFixtures,
+				fmt.Errorf("unable to load fixtures [%s], error:\n%s", strings.Join(v.Fixtures(), ", "), err),
+			)
+		}
+	}
+
+	client = r.cookieJars.clientFor(v, client)
+
+	if len(v.Steps()) > 0 {
+		return r.doScenario(ctx, v, client)
+	}
+
+	return r.doStep(ctx, v, client, r.config.Variables)
+}
+
+// doStep sends the request of the test and checks the response,
+// variables from the response are set to the given variables
+func (r *Runner) doStep(ctx context.Context, v models.TestInterface, client *http.Client, vars *variables.Variables) (*models.Result, error) {
+	startTime := time.Now()
+
+	// reset mocks
+	if r.sharesMocks(ctx) {
+		// prevent deriving the definition from previous test
+		r.config.Mocks.ResetDefinitions()
+		r.config.Mocks.ResetRunningContext()
+	}
+
+	// load mocks
+	if v.ServiceMocks() != nil {
+		if r.config.MocksLoader == nil {
+			return nil, models.NewExecutionError(models.StageMocks, errors.New("test declares mocks, but mocks are not configured"))
+		}
+		if err := r.config.MocksLoader.Load(v.ServiceMocks()); err != nil {
+			return nil, models.NewExecutionError(models.StageMocks, err)
 		}
 	}
 
 	// make pause
 	pause := v.Pause()
 	if pause > 0 {
-		time.Sleep(time.Duration(pause) * time.Second)
+		if err := sleep(ctx, time.Duration(pause)*time.Second); err != nil {
+			return nil, models.NewExecutionError(models.StagePause, err)
+		}
 		fmt.Printf("Sleep %ds before requests\n", pause)
 	}
 
+	retry := v.Retry()
+	if err := r.validateRetry(retry); err != nil {
+		return nil, models.NewExecutionError(models.StageRequestBuild, fmt.Errorf("invalid retry params: %s", err))
+	}
+	maxAttempts := 1
+	var interval time.Duration
+	if retry != nil && retry.MaxAttempts > 1 {
+		maxAttempts = retry.MaxAttempts
+		interval = retry.Interval
+	}
+
+	var result *models.Result
+	var snapshot *snapshotFile
+	declared := v.GetResponses()
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && r.sharesMocks(ctx) {
+			// each attempt is checked against the mocks from scratch
+			r.config.Mocks.ResetRunningContext()
+		}
+
+		var err error
+		result, err = r.doRequest(ctx, v, client)
+		if err != nil {
+			return nil, err
+		}
+		result.StartTime = startTime
+		result.Attempts = attempt
+
+		snapshot, err = r.resolveSnapshot(v, declared, result)
+		if err != nil {
+			return result, models.NewExecutionError(models.StageChecker, err)
+		}
+
+		passed, err := r.checkResult(ctx, v, result, retry)
+		if err != nil {
+			result.Duration = time.Since(startTime)
+			return result, err
+		}
+		if passed || attempt >= maxAttempts {
+			break
+		}
+
+		if err := sleep(ctx, interval); err != nil {
+			result.Duration = time.Since(startTime)
+			return result, models.NewExecutionError(models.StageRetry, err)
+		}
+		if retry.Backoff > 0 {
+			interval = time.Duration(float64(interval) * retry.Backoff)
+		}
+	}
+
+	result.Duration = time.Since(startTime)
+
+	// the snapshot is written from the response of the last attempt only
+	if snapshot != nil {
+		if err := snapshot.write(); err != nil {
+			return result, models.NewExecutionError(models.StageChecker, err)
+		}
+	}
+
+	// values captured by the expected response are set before ones from variables_to_set
+	vars.Load(result.CapturedVariables)
+
+	if err := setVariablesFromResponse(vars, v, result.ResponseContentType, result.ResponseBody, result.ResponseStatusCode); err != nil {
+		return result, models.NewExecutionError(models.StageVariables, err)
+	}
+
+	return result, nil
+}
+
+func (r *Runner) doRequest(ctx context.Context, v models.TestInterface, client *http.Client) (*models.Result, error) {
 	req, err := newRequest(r.config.Host, v)
 	if err != nil {
-		return nil, err
+		return nil, models.NewExecutionError(models.StageRequestBuild, err)
 	}
+	if v.FollowRedirects() {
+		ctx = withRedirects(ctx)
+	}
+	req = req.WithContext(ctx)
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, models.NewExecutionError(models.StageTransport, err)
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
@@ -127,16 +655,14 @@ func (r *Runner) executeTest(v models.TestInterface, client *http.Client) (*mode
 	_ = resp.Body.Close()
 
 	if err != nil {
-		return nil, err
+		return nil, models.NewExecutionError(models.StageTransport, err)
 	}
 
-	bodyStr := string(body)
-
 	result := models.Result{
 		Path:                req.URL.Path,
 		Query:               req.URL.RawQuery,
 		RequestBody:         actualRequestBody(req),
-		ResponseBody:        bodyStr,
+		ResponseBody:        string(body),
 		ResponseContentType: resp.Header.Get("Content-Type"),
 		ResponseStatusCode:  resp.StatusCode,
 		ResponseStatus:      resp.Status,
@@ -144,22 +670,113 @@ func (r *Runner) executeTest(v models.TestInterface, client *http.Client) (*mode
 		Test:                v,
 	}
 
+	return &result, nil
+}
+
+// checkResult runs checkers and collects their errors into the result,
+// it returns true if the checkers required by the retry params have passed
+func (r *Runner) checkResult(ctx context.Context, v models.TestInterface, result *models.Result, retry *models.RetryParams) (bool, error) {
+	passed := true
+
 	for _, c := range r.checkers {
-		errs, err := c.Check(v, &result)
+		var errs []error
+		var err error
+		if cc, ok := c.(checker.ContextCheckerInterface); ok {
+			errs, err = cc.CheckContext(ctx, v, result)
+		} else {
+			errs, err = c.Check(v, result)
+		}
 		if err != nil {
-			return nil, err
+			if name := checkerName(c); name != "" {
+				err = fmt.Errorf("%s checker: %s", name, err)
+			}
+			return false, models.NewExecutionError(models.StageChecker, err)
 		}
 		result.Errors = append(result.Errors, errs...)
+		if len(errs) > 0 && isRequiredCheck(checkerName(c), retry) {
+			passed = false
+		}
 	}
 
-	if err := r.setVariablesFromResponse(v, result.ResponseContentType, bodyStr, resp.StatusCode); err != nil {
-		return nil, err
+	// check mocks were called as expected
+	if r.sharesMocks(ctx) {
+		errs := r.config.Mocks.EndRunningContext()
+		result.Errors = append(result.Errors, errs...)
+		if len(errs) > 0 && isRequiredCheck("mocks", retry) {
+			passed = false
+		}
 	}
 
-	return &result, nil
+	return passed, nil
+}
+
+func (r *Runner) validateRetry(retry *models.RetryParams) error {
+	if retry == nil {
+		return nil
+	}
+	if retry.Backoff < 0 {
+		return fmt.Errorf("backoff must not be negative, got %v", retry.Backoff)
+	}
+	known := map[string]bool{"mocks": true}
+	for _, c := range r.checkers {
+		known[checkerName(c)] = true
+	}
+	for _, name := range retry.Until {
+		if !known[name] {
+			return fmt.Errorf("unknown checker %s", name)
+		}
+	}
+	return nil
+}
+
+func loadFixtures(ctx context.Context, loader fixtures.Loader, names []string) error {
+	if l, ok := loader.(fixtures.ContextLoader); ok {
+		return l.LoadContext(ctx, names)
+	}
+	return loader.Load(names)
 }
 
-func (r *Runner) setVariablesFromResponse(t models.TestInterface, contentType, body string, statusCode int) error {
+// isolatedLoader returns the loader if it isolates tests by other strategy than IsolationFixtures
+func isolatedLoader(loader fixtures.Loader) fixtures.IsolatedLoader {
+	if l, ok := loader.(fixtures.IsolatedLoader); ok && l.Isolation() != fixtures.IsolationFixtures {
+		return l
+	}
+	return nil
+}
+
+// sleep pauses the test until the duration passes or the context is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func checkerName(c checker.CheckerInterface) string {
+	if named, ok := c.(checker.NamedCheckerInterface); ok {
+		return named.Name()
+	}
+	return ""
+}
+
+// isRequiredCheck returns true if the check must pass to stop retrying
+func isRequiredCheck(name string, retry *models.RetryParams) bool {
+	if retry == nil || len(retry.Until) == 0 {
+		return true
+	}
+	for _, n := range retry.Until {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func setVariablesFromResponse(target *variables.Variables, t models.TestInterface, contentType, body string, statusCode int) error {
 
 	varTemplates := t.GetVariablesToSet()
 	if varTemplates == nil {
@@ -167,8 +784,15 @@ func (r *Runner) setVariablesFromResponse(t models.TestInterface, contentType, b
 	}
 
 	isJson := strings.Contains(contentType, "json") && body != ""
-
-	vars, err := variables.FromResponse(varTemplates[statusCode], body, isJson)
+	isXml := strings.Contains(contentType, "xml") && body != ""
+
+	var vars *variables.Variables
+	var err error
+	if isXml {
+		vars, err = variables.FromXmlResponse(varTemplates[statusCode], body)
+	} else {
+		vars, err = variables.FromResponse(varTemplates[statusCode], body, isJson)
+	}
 	if err != nil {
 		return err
 	}
@@ -177,7 +801,7 @@ func (r *Runner) setVariablesFromResponse(t models.TestInterface, contentType, b
 		return nil
 	}
 
-	r.config.Variables.Merge(vars)
+	target.Merge(vars)
 
 	return nil
 }
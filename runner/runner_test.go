@@ -0,0 +1,222 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rezikovka/gonkey/checker/response_body"
+	"github.com/rezikovka/gonkey/checker/response_header"
+	"github.com/rezikovka/gonkey/models"
+	"github.com/rezikovka/gonkey/testloader/yaml_file"
+	"github.com/rezikovka/gonkey/variables"
+)
+
+func TestSetVariablesFromXmlResponse(t *testing.T) {
+	body := `<order><id>42</id></order>`
+
+	tests := []struct {
+		name     string
+		toSet    map[string]string
+		body     string
+		expected map[string]string
+	}{
+		{
+			name:     "plain variable is set to the whole body",
+			toSet:    map[string]string{"order": ""},
+			body:     body,
+			expected: map[string]string{"order": body},
+		},
+		{
+			name:     "plain variable is set to the body which is not XML",
+			toSet:    map[string]string{"order": ""},
+			body:     "not a document",
+			expected: map[string]string{"order": "not a document"},
+		},
+		{
+			name:     "XPath is evaluated",
+			toSet:    map[string]string{"id": "/order/id"},
+			body:     body,
+			expected: map[string]string{"id": "42"},
+		},
+		{
+			name:     "empty path and XPath together",
+			toSet:    map[string]string{"order": "", "id": "//id"},
+			body:     body,
+			expected: map[string]string{"order": body, "id": "42"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test := &yaml_file.Test{}
+			test.VariablesToSet = map[int]map[string]string{200: tt.toSet}
+			vars := variables.New()
+
+			err := setVariablesFromResponse(vars, test, "application/soap+xml; charset=utf-8", tt.body, 200)
+			require.NoError(t, err)
+
+			for name, value := range tt.expected {
+				test := &yaml_file.Test{}
+				test.RequestURL = "{{ $" + name + " }}"
+				assert.Equal(t, value, vars.Apply(test).Path(), name)
+			}
+		})
+	}
+}
+
+// testsLoader passes the given tests to the runner
+type testsLoader []models.TestInterface
+
+func (l testsLoader) Load() (chan models.TestInterface, error) {
+	ch := make(chan models.TestInterface, len(l))
+	for _, v := range l {
+		ch <- v
+	}
+	close(ch)
+	return ch, nil
+}
+
+// resultsOutput collects results of the tests
+type resultsOutput struct {
+	results []*models.Result
+}
+
+func (o *resultsOutput) Process(_ models.TestInterface, result *models.Result) error {
+	o.results = append(o.results, result)
+	return nil
+}
+
+// roundTripperFunc makes the transport of the function
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// runTests runs the tests by the runner with the config and returns their results
+func runTests(t *testing.T, config *Config, tests ...models.TestInterface) []*models.Result {
+	if config.Variables == nil {
+		config.Variables = variables.New()
+	}
+	out := &resultsOutput{}
+	r := New(config, testsLoader(tests))
+	r.AddOutput(out)
+	_, err := r.Run()
+	require.NoError(t, err)
+	return out.results
+}
+
+func TestScenarioReportsFailedStep(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/broken" {
+			return nil, errors.New("connection refused")
+		}
+		return http.DefaultTransport.RoundTrip(req)
+	})
+
+	test := &yaml_file.Test{}
+	test.Name = "scenario"
+	test.StepTests = make([]yaml_file.Test, 3)
+	for i, path := range []string{"/ok", "/broken", "/ok"} {
+		step := &test.StepTests[i]
+		step.Name = fmt.Sprintf("request %s", path)
+		step.Method = http.MethodGet
+		step.RequestURL = path
+	}
+
+	results := runTests(t, &Config{Host: srv.URL, Transport: transport}, test)
+
+	require.Len(t, results, 1)
+	require.Len(t, results[0].Errors, 1)
+	err := results[0].Errors[0]
+	var execErr *models.ExecutionError
+	require.True(t, errors.As(err, &execErr))
+	assert.Equal(t, models.StageTransport, execErr.Stage)
+	assert.Contains(t, err.Error(), "step 2 (request /broken)")
+	assert.Contains(t, err.Error(), "connection refused")
+
+	// the step without response is reported by its request, the executed steps are kept
+	assert.Equal(t, "request /broken", results[0].Test.GetName())
+	require.Len(t, results[0].Steps, 1)
+	assert.Equal(t, "/ok", results[0].Steps[0].Path)
+	assert.Equal(t, "request /ok", results[0].Steps[0].Test.GetName())
+}
+
+func TestSnapshotOfRetriedTest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gonkey-snapshots")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	testFile := filepath.Join(dir, "order.yaml")
+	require.NoError(t, ioutil.WriteFile(testFile, []byte(`
+- name: ready order
+  method: GET
+  path: /order
+  retry:
+    maxAttempts: 3
+    interval: 1ms
+  response:
+    200: $snapshot
+  responseHeaders:
+    200:
+      X-Ready: "true"
+`), 0644))
+	snapshotFile := filepath.Join(dir, "__snapshots__", "order", "ready_order.200.json")
+
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Content-Type", "application/json")
+		if attempts%2 == 1 {
+			w.Header().Set("X-Ready", "false")
+			_, _ = w.Write([]byte(`{"status": "pending"}`))
+			return
+		}
+		w.Header().Set("X-Ready", "true")
+		_, _ = w.Write([]byte(`{"status": "done"}`))
+	}))
+	defer srv.Close()
+
+	run := func(update bool) *models.Result {
+		out := &resultsOutput{}
+		r := New(&Config{Host: srv.URL, Variables: variables.New(), UpdateSnapshots: update}, yaml_file.NewLoader(dir))
+		r.AddCheckers(response_body.NewChecker(), response_header.NewChecker())
+		r.AddOutput(out)
+		_, err := r.Run()
+		require.NoError(t, err)
+		require.Len(t, out.results, 1)
+		return out.results[0]
+	}
+
+	for _, update := range []bool{false, true} {
+		// the snapshot is made from the last attempt, even if it exists in the update mode
+		attempts = 0
+		result := run(update)
+		assert.Empty(t, result.Errors)
+		assert.Equal(t, 2, result.Attempts)
+		data, err := ioutil.ReadFile(snapshotFile)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"status": "done"}`, string(data))
+		require.NoError(t, ioutil.WriteFile(snapshotFile, []byte(`{"status": "pending"}`), 0644))
+	}
+
+	// the existing snapshot is compared with each attempt
+	require.NoError(t, ioutil.WriteFile(snapshotFile, []byte(`{"status": "done"}`), 0644))
+	attempts = 0
+	result := run(false)
+	assert.Empty(t, result.Errors)
+	assert.Equal(t, 2, result.Attempts)
+}
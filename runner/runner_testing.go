@@ -2,30 +2,65 @@ package runner
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
 	"net/http/httptest"
 	"os"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/joho/godotenv"
 
 	"github.com/rezikovka/gonkey/checker/response_body"
+	"github.com/rezikovka/gonkey/checker/response_cookie"
 	"github.com/rezikovka/gonkey/checker/response_db"
 	"github.com/rezikovka/gonkey/checker/response_header"
 	"github.com/rezikovka/gonkey/fixtures"
+	"github.com/rezikovka/gonkey/mocks"
+	"github.com/rezikovka/gonkey/models"
 	"github.com/rezikovka/gonkey/output"
+	"github.com/rezikovka/gonkey/output/allure_report"
+	"github.com/rezikovka/gonkey/output/junit"
 	testingOutput "github.com/rezikovka/gonkey/output/testing"
 	"github.com/rezikovka/gonkey/testloader/yaml_file"
 	"github.com/rezikovka/gonkey/variables"
 )
 
+// handlerHost is the host of requests to the handler served in-process
+const handlerHost = "http://localhost"
+
+// RunWithTestingParams define the tested service by one of Server, Handler or BaseURL
 type RunWithTestingParams struct {
-	Server      *httptest.Server
-	TestsDir    string
-	FixturesDir string
-	DB          *sql.DB
-	DbType      fixtures.DbType
-	EnvFilePath string
-	OutputFunc  output.OutputInterface
+	Server          *httptest.Server
+	Handler         http.Handler // served in-process without network
+	BaseURL         string       // URL of the running service, e.g. http://localhost:8080
+	Client          *http.Client // client which sends requests, it can't be used with Handler
+	Transport       http.RoundTripper
+	ClientConfig    *ClientConfig // configuration of the default transport, it can't be used with Transport or Handler
+	TestsDir        string
+	Mocks           *mocks.Mocks
+	FixturesDir     string
+	DB              *sql.DB
+	DbType          fixtures.DbType
+	DbIsolation     fixtures.Isolation // isolation of tests in DB, IsolationSavepoint requires DB with one connection
+	ReuseFixtures   bool               // don't reload the fixtures of the previous test if their tables are unchanged
+	EnvFilePath     string
+	OutputFunc      output.OutputInterface
+	AllureDir       string
+	JUnitFile       string
+	Concurrency     int
+	Parallel        bool // run subtests of tests which may run at the same time as others by t.Parallel()
+	FailFast        bool
+	Timeout         time.Duration
+	TestTimeout     time.Duration
+	Tags            []string // run only tests having any of the tags
+	SkipTags        []string // skip tests having any of the tags
+	Run             string   // run only tests which names match the regular expression
+	UpdateSnapshots bool     // overwrite snapshots of responses instead of comparing with them
 }
 
 // RunWithTesting is a helper function the wraps the common Run and provides simple way
@@ -41,12 +76,51 @@ func RunWithTesting(t *testing.T, params *RunWithTestingParams) {
 
 	var fixturesLoader fixtures.Loader
 	if params.DB != nil {
-		fixturesLoader = fixtures.NewLoader(&fixtures.Config{
-			Location: params.FixturesDir,
-			DB:       params.DB,
-			Debug:    debug,
-			DbType:   params.DbType,
-		})
+		fixturesConfig := &fixtures.Config{
+			Location:  params.FixturesDir,
+			DB:        params.DB,
+			Debug:     debug,
+			DbType:    params.DbType,
+			Isolation: params.DbIsolation,
+			Reuse:     params.ReuseFixtures,
+		}
+		if err := fixtures.ValidateConfig(fixturesConfig); err != nil {
+			t.Fatal(err)
+		}
+		fixturesLoader = fixtures.NewLoader(fixturesConfig)
+	}
+
+	var mocksLoader *mocks.Loader
+	if params.Mocks != nil {
+		mocksLoader = mocks.NewLoader(params.Mocks)
+	}
+
+	tags := params.Tags
+	if tags == nil {
+		tags = SplitList(os.Getenv("GONKEY_TAGS"))
+	}
+	skipTags := params.SkipTags
+	if skipTags == nil {
+		skipTags = SplitList(os.Getenv("GONKEY_SKIP_TAGS"))
+	}
+	runPattern := params.Run
+	if runPattern == "" {
+		runPattern = os.Getenv("GONKEY_RUN")
+	}
+	var namePattern *regexp.Regexp
+	if runPattern != "" {
+		var err error
+		namePattern, err = regexp.Compile(runPattern)
+		if err != nil {
+			t.Fatalf("invalid run pattern: %s", err)
+		}
+	}
+
+	updateSnapshots := params.UpdateSnapshots || os.Getenv("GONKEY_UPDATE_SNAPSHOTS") != ""
+
+	host, transport, err := targetOf(params)
+	if err != nil {
+		t.Fatal(err)
 	}
 
 	yamlLoader := yaml_file.NewLoader(params.TestsDir)
@@ -54,28 +128,196 @@ func RunWithTesting(t *testing.T, params *RunWithTestingParams) {
 
 	r := New(
 		&Config{
-			Host:           params.Server.URL,
-			FixturesLoader: fixturesLoader,
-			Variables:      variables.New(),
+			Host:            host,
+			Client:          params.Client,
+			Transport:       transport,
+			ClientConfig:    params.ClientConfig,
+			FixturesLoader:  fixturesLoader,
+			Mocks:           params.Mocks,
+			MocksLoader:     mocksLoader,
+			Variables:       variables.New(),
+			Concurrency:     params.Concurrency,
+			FailFast:        params.FailFast,
+			Timeout:         params.Timeout,
+			TestTimeout:     params.TestTimeout,
+			Tags:            tags,
+			SkipTags:        skipTags,
+			NamePattern:     namePattern,
+			UpdateSnapshots: updateSnapshots,
 		},
 		yamlLoader,
 	)
 
 	if params.OutputFunc != nil {
 		r.AddOutput(params.OutputFunc)
-	} else {
-		r.AddOutput(testingOutput.NewOutput(t))
+	}
+
+	allureDir := params.AllureDir
+	if allureDir == "" {
+		allureDir = os.Getenv("GONKEY_ALLURE_DIR")
+	}
+	if allureDir != "" {
+		allureOutput, err := allure_report.NewOutput("Gonkey", allureDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.AddOutput(allureOutput)
+	}
+
+	junitFile := params.JUnitFile
+	if junitFile == "" {
+		junitFile = os.Getenv("GONKEY_JUNIT_FILE")
+	}
+	if junitFile != "" {
+		r.AddOutput(junit.NewOutput(junitFile))
 	}
 
 	r.AddCheckers(response_body.NewChecker())
 	r.AddCheckers(response_header.NewChecker())
+	r.AddCheckers(response_cookie.NewChecker())
 
 	if params.DB != nil {
-		r.AddCheckers(response_db.NewChecker(params.DB))
+		r.AddCheckers(response_db.NewCheckerWithDbType(params.DB, params.DbType))
 	}
 
-	_, err := r.Run()
-	if err != nil {
-		t.Fatal(err)
+	report := params.OutputFunc == nil
+	s := newSubtests(t, report)
+	r.SetTestWrapper(s.run)
+	if !params.Parallel {
+		if _, err := r.Run(); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	// parallel subtests start when the function of their parent returns,
+	// so the run goes on in background and its error is reported when all subtests are finished
+	var runErr error
+	dispatched := make(chan struct{})
+	finished := make(chan struct{})
+	r.SetParallelTestWrapper(s.runParallel, func() { close(dispatched) })
+	go func() {
+		defer close(finished)
+		_, runErr = r.Run()
+	}()
+	t.Cleanup(func() {
+		<-finished
+		if runErr != nil {
+			t.Error(runErr)
+		}
+	})
+	select {
+	case <-dispatched:
+	case <-finished:
+	}
+}
+
+// targetOf returns the host of the tested service and the transport to send requests to it
+func targetOf(params *RunWithTestingParams) (string, http.RoundTripper, error) {
+	targets := 0
+	for _, set := range []bool{params.Server != nil, params.Handler != nil, params.BaseURL != ""} {
+		if set {
+			targets++
+		}
+	}
+	if targets != 1 {
+		return "", nil, errors.New("exactly one of Server, Handler or BaseURL must be set")
+	}
+
+	switch {
+	case params.Server != nil:
+		return params.Server.URL, params.Transport, nil
+	case params.Handler != nil:
+		if params.Client != nil || params.Transport != nil || params.ClientConfig != nil {
+			return "", nil, errors.New("handler can't be used with Client, Transport or ClientConfig")
+		}
+		return handlerHost, NewHandlerTransport(params.Handler), nil
+	default:
+		return strings.TrimRight(params.BaseURL, "/"), params.Transport, nil
+	}
+}
+
+// SplitList splits comma-separated list skipping empty items, e.g. tags from flags or env vars
+func SplitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// subtests runs tests as Go subtests, so they can be selected by `go test -run`
+type subtests struct {
+	t *testing.T
+	// results are reported to subtests
+	report bool
+
+	mu        sync.Mutex
+	usedNames map[string]bool
+}
+
+func newSubtests(t *testing.T, report bool) *subtests {
+	return &subtests{
+		t:         t,
+		report:    report,
+		usedNames: make(map[string]bool),
+	}
+}
+
+// run executes the test in the subtest
+func (s *subtests) run(v models.TestInterface, run func() *models.Result) {
+	s.t.Run(s.name(v), func(t *testing.T) {
+		s.process(t, v, run())
+	})
+}
+
+// runParallel executes the test in the parallel subtest, it returns true
+// if the subtest is started, then the test is executed after the function of the parent returns
+func (s *subtests) runParallel(v models.TestInterface, run func() *models.Result) bool {
+	started := false
+	s.t.Run(s.name(v), func(t *testing.T) {
+		started = true
+		t.Parallel()
+		s.process(t, v, run())
+	})
+	return started
+}
+
+func (s *subtests) process(t *testing.T, v models.TestInterface, result *models.Result) {
+	if result == nil || !s.report {
+		return
+	}
+	if result.Skipped() {
+		t.Skip(result.SkipReason)
+	}
+	if err := testingOutput.NewOutput(t).Process(v, result); err != nil {
+		t.Error(err)
+	}
+}
+
+// name returns the name of the subtest, names of tests are suffixed by numbers to be unique
+func (s *subtests) name(v models.TestInterface) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	base := subtestName(v.GetName())
+	name := base
+	for n := 2; s.usedNames[name]; n++ {
+		name = fmt.Sprintf("%s_%d", base, n)
+	}
+	s.usedNames[name] = true
+	return name
+}
+
+var subtestNameRx = regexp.MustCompile(`[^\w.-]+`)
+
+// subtestName replaces characters which are not allowed or ambiguous in `go test -run` patterns
+func subtestName(name string) string {
+	name = strings.Trim(subtestNameRx.ReplaceAllString(name, "_"), "_")
+	if name == "" {
+		return "test"
 	}
+	return name
 }
@@ -0,0 +1,59 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rezikovka/gonkey/models"
+)
+
+// doScenario executes steps of the test one by one until a step fails.
+// Variables set by the steps are visible to the following steps of the scenario only.
+func (r *Runner) doScenario(ctx context.Context, v models.TestInterface, client *http.Client) (*models.Result, error) {
+	startTime := time.Now()
+	vars := r.config.Variables.Copy()
+
+	var steps []*models.Result
+	var result *models.Result
+	for i, step := range v.Steps() {
+		vars.Load(step.GetVariables())
+		step = vars.Apply(step)
+
+		var err error
+		result, err = r.doStep(ctx, step, client, vars)
+		if result == nil {
+			// the step has got no response, the scenario is reported by the request of the step
+			result = &models.Result{Test: step}
+		} else {
+			steps = append(steps, result)
+		}
+		if err != nil {
+			return scenarioResult(result, steps, startTime), stepError(i, step, err)
+		}
+		if !result.Passed() {
+			// the result of the failed step is reported, the rest of steps are skipped
+			return scenarioResult(result, steps, startTime), nil
+		}
+	}
+
+	return scenarioResult(result, steps, startTime), nil
+}
+
+// scenarioResult makes the result of the scenario from the result of its last executed step
+func scenarioResult(last *models.Result, steps []*models.Result, startTime time.Time) *models.Result {
+	result := *last
+	result.Steps = steps
+	result.StartTime = startTime
+	result.Duration = time.Since(startTime)
+	return &result
+}
+
+// stepError tells which step of the scenario has failed, the stage of the execution error is kept
+func stepError(i int, step models.TestInterface, err error) error {
+	if execErr, ok := err.(*models.ExecutionError); ok {
+		return models.NewExecutionError(execErr.Stage, fmt.Errorf("step %d (%s): %w", i+1, step.GetName(), execErr.Err))
+	}
+	return fmt.Errorf("step %d (%s): %w", i+1, step.GetName(), err)
+}
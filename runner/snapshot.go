@@ -0,0 +1,153 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rezikovka/gonkey/models"
+)
+
+const (
+	// snapshotsDir is the directory next to the test file where snapshots are stored
+	snapshotsDir = "__snapshots__"
+	// snapshotMaskValue replaces masked values in snapshots, so they match any value
+	snapshotMaskValue = "$any"
+)
+
+var snapshotNameRx = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// resolveSnapshot sets the snapshot as the expected response if the test compares the response with it,
+// declared are the responses of the test before snapshots have been set by previous attempts.
+// If the snapshot doesn't exist or snapshots are updated, it's made from the response and returned
+// to be written once the response of the last attempt is known.
+func (r *Runner) resolveSnapshot(v models.TestInterface, declared map[int]string, result *models.Result) (*snapshotFile, error) {
+	code := result.ResponseStatusCode
+	if expected, ok := declared[code]; ok && expected != models.SnapshotResponse || !ok && !v.Snapshot() {
+		return nil, nil
+	}
+
+	fileName := snapshotFileName(v, code, result.ResponseContentType)
+	var pending *snapshotFile
+	data, err := ioutil.ReadFile(fileName)
+	if r.config.UpdateSnapshots || os.IsNotExist(err) {
+		data, err = makeSnapshot(result, v.SnapshotMask())
+		if err != nil {
+			return nil, fmt.Errorf("unable to make snapshot %s: %s", fileName, err)
+		}
+		pending = &snapshotFile{name: fileName, data: data}
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read snapshot: %s", err)
+	}
+
+	responses := make(map[int]string, len(declared)+1)
+	for c, response := range declared {
+		responses[c] = response
+	}
+	responses[code] = string(data)
+	v.SetResponses(responses)
+	return pending, nil
+}
+
+// snapshotFile is the snapshot made from the response
+type snapshotFile struct {
+	name string
+	data []byte
+}
+
+func (f *snapshotFile) write() error {
+	if err := os.MkdirAll(filepath.Dir(f.name), 0755); err != nil {
+		return fmt.Errorf("unable to write snapshot: %s", err)
+	}
+	if err := ioutil.WriteFile(f.name, f.data, 0644); err != nil {
+		return fmt.Errorf("unable to write snapshot: %s", err)
+	}
+	return nil
+}
+
+// snapshotFileName returns the file of the snapshot of the response: __snapshots__/<test file>/<test name>.<status>.<ext>
+func snapshotFileName(v models.TestInterface, code int, contentType string) string {
+	dir := snapshotsDir
+	if testFile := v.GetFileName(); testFile != "" {
+		base := strings.TrimSuffix(filepath.Base(testFile), filepath.Ext(testFile))
+		dir = filepath.Join(filepath.Dir(testFile), snapshotsDir, base)
+	}
+
+	ext := "txt"
+	switch {
+	case strings.Contains(contentType, "json"):
+		ext = "json"
+	case strings.Contains(contentType, "xml"):
+		ext = "xml"
+	}
+
+	name := strings.Trim(snapshotNameRx.ReplaceAllString(v.GetName(), "_"), "_")
+	return filepath.Join(dir, fmt.Sprintf("%s.%d.%s", name, code, ext))
+}
+
+// makeSnapshot returns the body of the response to store, JSON is indented and masked values are replaced
+func makeSnapshot(result *models.Result, mask []string) ([]byte, error) {
+	if !strings.Contains(result.ResponseContentType, "json") || result.ResponseBody == "" {
+		if len(mask) > 0 {
+			return nil, errors.New("snapshotMask is supported for JSON responses only")
+		}
+		return []byte(result.ResponseBody), nil
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(result.ResponseBody))
+	// numbers are stored as they are in the response
+	decoder.UseNumber()
+	var body interface{}
+	if err := decoder.Decode(&body); err != nil {
+		return nil, fmt.Errorf("could not parse response: %s", err)
+	}
+
+	for _, path := range mask {
+		body = maskValue(body, strings.Split(path, "."))
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(body); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// maskValue replaces values at the path, * or # matches all elements of the object or the array,
+// missing values are ignored
+func maskValue(value interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return snapshotMaskValue
+	}
+
+	key, rest := path[0], path[1:]
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if key == "*" || key == "#" {
+			for k, item := range v {
+				v[k] = maskValue(item, rest)
+			}
+		} else if item, ok := v[key]; ok {
+			v[key] = maskValue(item, rest)
+		}
+	case []interface{}:
+		if key == "*" || key == "#" {
+			for i, item := range v {
+				v[i] = maskValue(item, rest)
+			}
+		} else if i, err := strconv.Atoi(key); err == nil && i >= 0 && i < len(v) {
+			v[i] = maskValue(v[i], rest)
+		}
+	}
+	return value
+}
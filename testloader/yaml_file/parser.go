@@ -7,6 +7,8 @@ import (
 	"text/template"
 
 	"gopkg.in/yaml.v2"
+
+	"github.com/rezikovka/gonkey/models"
 )
 
 func parseTestDefinitionFile(absPath string) ([]Test, error) {
@@ -28,6 +30,12 @@ func parseTestDefinitionFile(absPath string) ([]Test, error) {
 		if testCases, err := makeTestFromDefinition(definition); err != nil {
 			return nil, err
 		} else {
+			for i := range testCases {
+				testCases[i].FileName = absPath
+				for j := range testCases[i].StepTests {
+					testCases[i].StepTests[j].FileName = absPath
+				}
+			}
 			tests = append(tests, testCases...)
 		}
 	}
@@ -64,6 +72,16 @@ func substituteArgsToMap(tmpl map[string]string, args map[string]interface{}) (m
 
 // Make tests from the given test definition.
 func makeTestFromDefinition(testDefinition TestDefinition) ([]Test, error) {
+	switch testDefinition.CookieJarValue {
+	case "", models.CookieJarFile, models.CookieJarScenario:
+	default:
+		return nil, fmt.Errorf("test %s: unknown cookie jar mode %s", testDefinition.Name, testDefinition.CookieJarValue)
+	}
+
+	if len(testDefinition.Steps) > 0 {
+		return makeScenarioFromDefinition(testDefinition)
+	}
+
 	var tests []Test
 
 	// test definition has no cases, so using request/response as is
@@ -90,6 +108,12 @@ func makeTestFromDefinition(testDefinition TestDefinition) ([]Test, error) {
 	for caseIdx, testCase := range testDefinition.Cases {
 		test := Test{TestDefinition: testDefinition}
 		test.Name = fmt.Sprintf("%s #%d", test.Name, caseIdx)
+		if testCase.Skip != "" {
+			test.SkipValue = testCase.Skip
+		}
+		if testCase.Only {
+			test.OnlyValue = true
+		}
 
 		// substitute RequestArgs to different parts of request
 		test.RequestURL, err = substituteArgs(requestURLTmpl, testCase.RequestArgs)
@@ -176,3 +200,37 @@ func makeTestFromDefinition(testDefinition TestDefinition) ([]Test, error) {
 
 	return tests, nil
 }
+
+// Make a scenario test from the given test definition, each step of the scenario is made as a separate test.
+func makeScenarioFromDefinition(testDefinition TestDefinition) ([]Test, error) {
+	if len(testDefinition.Cases) > 0 {
+		return nil, fmt.Errorf("test %s: cases are not supported in tests with steps", testDefinition.Name)
+	}
+	if testDefinition.Method != "" || testDefinition.RequestURL != "" || testDefinition.RequestTmpl != "" ||
+		testDefinition.ResponseTmpls != nil || testDefinition.DbQueryTmpl != "" || testDefinition.MocksDefinition != nil {
+		return nil, fmt.Errorf("test %s: request, response, db query and mocks of the test with steps must be defined in steps", testDefinition.Name)
+	}
+
+	test := Test{TestDefinition: testDefinition}
+	for i, stepDefinition := range testDefinition.Steps {
+		if len(stepDefinition.Steps) > 0 || len(stepDefinition.Cases) > 0 || len(stepDefinition.FixtureFiles) > 0 {
+			return nil, fmt.Errorf("test %s, step %d: steps can not have cases, fixtures or nested steps", testDefinition.Name, i+1)
+		}
+		if stepDefinition.SkipValue != "" || stepDefinition.OnlyValue || stepDefinition.CookieJarValue != "" {
+			return nil, fmt.Errorf("test %s, step %d: skip, only and cookieJar can be set to the whole test only", testDefinition.Name, i+1)
+		}
+		stepTests, err := makeTestFromDefinition(stepDefinition)
+		if err != nil {
+			return nil, err
+		}
+		step := stepTests[0]
+		if stepDefinition.Name != "" {
+			step.Name = fmt.Sprintf("%s [step %d: %s]", testDefinition.Name, i+1, stepDefinition.Name)
+		} else {
+			step.Name = fmt.Sprintf("%s [step %d]", testDefinition.Name, i+1)
+		}
+		test.StepTests = append(test.StepTests, step)
+	}
+
+	return []Test{test}, nil
+}
@@ -1,6 +1,8 @@
 package yaml_file
 
 import (
+	"time"
+
 	"github.com/rezikovka/gonkey/models"
 )
 
@@ -14,6 +16,8 @@ type Test struct {
 	ResponseHeaders map[int]map[string]string
 	DbQuery         string
 	DbResponse      []string
+	FileName        string
+	StepTests       []Test
 }
 
 func (t *Test) ToQuery() string {
@@ -50,6 +54,16 @@ func (t *Test) GetResponseHeaders(code int) (map[string]string, bool) {
 	return val, ok
 }
 
+func (t *Test) GetResponseCookies(code int) (map[string]*models.CookieAssertion, bool) {
+	val, ok := t.ResponseCookies[code]
+	return val, ok
+}
+
+func (t *Test) GetResponseAssertions(code int) ([]*models.ResponseAssertion, bool) {
+	val, ok := t.Assertions[code]
+	return val, ok
+}
+
 func (t *Test) NeedsCheckingValues() bool {
 	return !t.ComparisonParams.IgnoreValues
 }
@@ -58,6 +72,22 @@ func (t *Test) GetName() string {
 	return t.Name
 }
 
+func (t *Test) GetFileName() string {
+	return t.FileName
+}
+
+func (t *Test) Snapshot() bool {
+	return t.SnapshotValue
+}
+
+func (t *Test) SnapshotMask() []string {
+	return t.SnapshotMaskList
+}
+
+func (t *Test) Tags() []string {
+	return t.TagsList
+}
+
 func (t *Test) IgnoreArraysOrdering() bool {
 	return t.ComparisonParams.IgnoreArraysOrdering
 }
@@ -70,14 +100,51 @@ func (t *Test) Fixtures() []string {
 	return t.FixtureFiles
 }
 
+func (t *Test) SkipReason() string {
+	return t.SkipValue
+}
+
+func (t *Test) Only() bool {
+	return t.OnlyValue
+}
+
 func (t *Test) Pause() int {
 	return t.PauseValue
 }
 
+func (t *Test) Retry() *models.RetryParams {
+	if t.RetryParams == nil {
+		return nil
+	}
+	return &models.RetryParams{
+		MaxAttempts: t.RetryParams.MaxAttempts,
+		Interval:    time.Duration(t.RetryParams.Interval),
+		Backoff:     t.RetryParams.Backoff,
+		Until:       t.RetryParams.Until,
+	}
+}
+
+func (t *Test) Timeout() time.Duration {
+	return time.Duration(t.TimeoutValue)
+}
+
+// Parallel returns false if the test must not be run concurrently with other test files
+func (t *Test) Parallel() bool {
+	return t.ParallelValue == nil || *t.ParallelValue
+}
+
+func (t *Test) FollowRedirects() bool {
+	return t.RedirectsValue
+}
+
 func (t *Test) Cookies() map[string]string {
 	return t.CookiesVal
 }
 
+func (t *Test) CookieJar() string {
+	return t.CookieJarValue
+}
+
 func (t *Test) Headers() map[string]string {
 	return t.HeadersVal
 }
@@ -100,6 +167,10 @@ func (t *Test) GetVariables() map[string]string {
 	return t.Variables
 }
 
+func (t *Test) ServiceMocks() map[string]interface{} {
+	return t.MocksDefinition
+}
+
 func (t *Test) GetForm() *models.Form {
 	return t.Form
 }
@@ -108,6 +179,17 @@ func (t *Test) GetVariablesToSet() map[int]map[string]string {
 	return t.VariablesToSet
 }
 
+func (t *Test) Steps() []models.TestInterface {
+	if len(t.StepTests) == 0 {
+		return nil
+	}
+	steps := make([]models.TestInterface, len(t.StepTests))
+	for i := range t.StepTests {
+		steps[i] = &t.StepTests[i]
+	}
+	return steps
+}
+
 func (t *Test) Clone() models.TestInterface {
 	res := *t
 
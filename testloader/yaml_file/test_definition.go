@@ -1,9 +1,15 @@
 package yaml_file
 
-import "github.com/rezikovka/gonkey/models"
+import (
+	"fmt"
+	"time"
+
+	"github.com/rezikovka/gonkey/models"
+)
 
 type TestDefinition struct {
 	Name             string                    `json:"name" yaml:"name"`
+	TagsList         []string                  `json:"tags" yaml:"tags"`
 	Variables        map[string]string         `json:"variables" yaml:"variables"`
 	VariablesToSet   VariablesToSet            `json:"variables_to_set" yaml:"variables_to_set"`
 	Form             *models.Form              `json:"form" yaml:"form"`
@@ -13,14 +19,27 @@ type TestDefinition struct {
 	RequestTmpl      string                    `json:"request" yaml:"request"`
 	ResponseTmpls    map[int]string            `json:"response" yaml:"response"`
 	ResponseHeaders  map[int]map[string]string `json:"responseHeaders" yaml:"responseHeaders"`
+	ResponseCookies  cookieAssertions          `json:"responseCookies" yaml:"responseCookies"`
+	Assertions       responseAssertions        `json:"responseAssertions" yaml:"responseAssertions"`
+	SnapshotValue    bool                      `json:"snapshot" yaml:"snapshot"`
+	SnapshotMaskList []string                  `json:"snapshotMask" yaml:"snapshotMask"`
 	HeadersVal       map[string]string         `json:"headers" yaml:"headers"`
 	CookiesVal       map[string]string         `json:"cookies" yaml:"cookies"`
+	CookieJarValue   string                    `json:"cookieJar" yaml:"cookieJar"`
 	Cases            []CaseData                `json:"cases" yaml:"cases"`
 	ComparisonParams comparisonParams          `json:"comparisonParams" yaml:"comparisonParams"`
 	FixtureFiles     []string                  `json:"fixtures" yaml:"fixtures"`
+	MocksDefinition  map[string]interface{}    `json:"mocks" yaml:"mocks"`
 	PauseValue       int                       `json:"pause" yaml:"pause"`
+	SkipValue        string                    `json:"skip" yaml:"skip"`
+	OnlyValue        bool                      `json:"only" yaml:"only"`
+	ParallelValue    *bool                     `json:"parallel" yaml:"parallel"`
+	RedirectsValue   bool                      `json:"followRedirects" yaml:"followRedirects"`
+	RetryParams      *retryParams              `json:"retry" yaml:"retry"`
+	TimeoutValue     duration                  `json:"timeout" yaml:"timeout"`
 	DbQueryTmpl      string                    `json:"dbQuery" yaml:"dbQuery"`
 	DbResponseTmpl   []string                  `json:"dbResponse" yaml:"dbResponse"`
+	Steps            []TestDefinition          `json:"steps" yaml:"steps"`
 }
 
 type CaseData struct {
@@ -29,14 +48,45 @@ type CaseData struct {
 	DbQueryArgs    map[string]interface{}         `json:"dbQueryArgs" yaml:"dbQueryArgs"`
 	DbResponseArgs map[string]interface{}         `json:"dbResponseArgs" yaml:"dbResponseArgs"`
 	DbResponse     []string                       `json:"dbResponse" yaml:"dbResponse"`
+	Skip           string                         `json:"skip" yaml:"skip"`
+	Only           bool                           `json:"only" yaml:"only"`
 }
 
+// cookieAssertions are the cookies expected in the response by status code and cookie name
+type cookieAssertions map[int]map[string]*models.CookieAssertion
+
+// responseAssertions are the assertions on the response body by status code
+type responseAssertions map[int][]*models.ResponseAssertion
+
 type comparisonParams struct {
 	IgnoreValues         bool `json:"ignoreValues" yaml:"ignoreValues"`
 	IgnoreArraysOrdering bool `json:"ignoreArraysOrdering" yaml:"ignoreArraysOrdering"`
 	DisallowExtraFields  bool `json:"disallowExtraFields" yaml:"disallowExtraFields"`
 }
 
+type retryParams struct {
+	MaxAttempts int      `json:"maxAttempts" yaml:"maxAttempts"`
+	Interval    duration `json:"interval" yaml:"interval"`
+	Backoff     float64  `json:"backoff" yaml:"backoff"`
+	Until       []string `json:"until" yaml:"until"`
+}
+
+// duration is a time.Duration written in YAML as a string, e.g. "500ms" or "2s"
+type duration time.Duration
+
+func (d *duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %s: %s", s, err)
+	}
+	*d = duration(parsed)
+	return nil
+}
+
 type VariablesToSet map[int]map[string]string
 
 /*
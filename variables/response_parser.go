@@ -4,6 +4,8 @@ import (
 	"fmt"
 
 	"github.com/tidwall/gjson"
+
+	"github.com/rezikovka/gonkey/xmldoc"
 )
 
 func FromResponse(varsToSet map[string]string, body string, isJson bool) (vars *Variables, err error) {
@@ -27,6 +29,43 @@ func FromResponse(varsToSet map[string]string, body string, isJson bool) (vars *
 
 }
 
+// FromXmlResponse sets variables by XPath expressions applied to the XML body,
+// variables with empty paths are set to the whole body like for plain-text responses
+func FromXmlResponse(varsToSet map[string]string, body string) (*Variables, error) {
+
+	names, paths := split(varsToSet)
+
+	if !hasPaths(paths) {
+		return fromPlainText(names, body)
+	}
+
+	doc, err := xmldoc.Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse XML response: %s", err)
+	}
+
+	vars := New()
+
+	for n, path := range paths {
+		if path == "" {
+			vars.Add(NewVariable(names[n], body))
+			continue
+		}
+		value, ok, err := doc.Query(path)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil,
+				fmt.Errorf("path '%s' doesn't exist in given xml", path)
+		}
+
+		vars.Add(NewVariable(names[n], value))
+	}
+
+	return vars, nil
+}
+
 func fromJson(names, paths []string, body string) (*Variables, error) {
 
 	vars := New()
@@ -58,6 +97,15 @@ func fromPlainText(names []string, body string) (*Variables, error) {
 	return New().Add(NewVariable(names[0], body)), nil
 }
 
+func hasPaths(paths []string) bool {
+	for _, path := range paths {
+		if path != "" {
+			return true
+		}
+	}
+	return false
+}
+
 // split returns keys and values of given map as separate slices
 func split(m map[string]string) ([]string, []string) {
 
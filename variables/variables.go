@@ -2,11 +2,13 @@ package variables
 
 import (
 	"regexp"
+	"sync"
 
 	"github.com/rezikovka/gonkey/models"
 )
 
 type Variables struct {
+	mu        sync.RWMutex
 	variables variables
 }
 
@@ -22,6 +24,9 @@ func New() *Variables {
 
 // Load adds new variables and replaces values of existing
 func (vs *Variables) Load(variables map[string]string) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
 	for n, v := range variables {
 		variable := NewVariable(n, v)
 
@@ -33,6 +38,9 @@ func (vs *Variables) Load(variables map[string]string) {
 func (vs *Variables) Set(name, value string) {
 	v := NewVariable(name, value)
 
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
 	vs.variables[name] = v
 }
 
@@ -44,6 +52,9 @@ func (vs *Variables) Apply(t models.TestInterface) models.TestInterface {
 		return newTest
 	}
 
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
 	newTest.SetQuery(vs.perform(newTest.ToQuery()))
 	newTest.SetMethod(vs.perform(newTest.GetMethod()))
 	newTest.SetPath(vs.perform(newTest.Path()))
@@ -59,14 +70,32 @@ func (vs *Variables) Apply(t models.TestInterface) models.TestInterface {
 	return newTest
 }
 
+// Copy returns new set of variables with the same values
+func (vs *Variables) Copy() *Variables {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	res := New()
+	for k, v := range vs.variables {
+		res.variables[k] = v
+	}
+	return res
+}
+
 // Merge adds given variables to set or overrides existed
 func (vs *Variables) Merge(vars *Variables) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
 	for k, v := range vars.variables {
 		vs.variables[k] = v
 	}
 }
 
 func (vs *Variables) Len() int {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
 	return len(vs.variables)
 }
 
@@ -135,6 +164,9 @@ func (vs *Variables) performResponses(responses map[int]string) map[int]string {
 }
 
 func (vs *Variables) Add(v *Variable) *Variables {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
 	vs.variables[v.name] = v
 
 	return vs
@@ -0,0 +1,142 @@
+package xmldoc
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+const (
+	// AttrPrefix starts keys of attributes in the value of the element
+	AttrPrefix = "@"
+	// TextKey is the key of the text of the element which has attributes or child elements
+	TextKey = "#text"
+)
+
+// Node is an element of the XML document
+type Node struct {
+	Name     string // local name, the namespace is ignored
+	Attrs    []xml.Attr
+	Children []*Node
+	Text     string // text of the element without text of child elements
+	Parent   *Node
+}
+
+// Parse parses the XML document and returns its root element
+func Parse(data string) (*Node, error) {
+	decoder := xml.NewDecoder(strings.NewReader(data))
+	// documents in other encodings are decoded as is
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	var root, current *Node
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &Node{Name: t.Name.Local, Attrs: attrs(t.Attr), Parent: current}
+			if current == nil {
+				if root != nil {
+					return nil, errors.New("document has several root elements")
+				}
+				root = node
+			} else {
+				current.Children = append(current.Children, node)
+			}
+			current = node
+		case xml.EndElement:
+			current = current.Parent
+		case xml.CharData:
+			if current != nil {
+				current.Text += string(t)
+			} else if strings.TrimSpace(string(t)) != "" {
+				return nil, errors.New("text outside of the root element")
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, errors.New("document has no root element")
+	}
+	return root, nil
+}
+
+// attrs returns attributes without namespace declarations
+func attrs(list []xml.Attr) []xml.Attr {
+	var res []xml.Attr
+	for _, a := range list {
+		if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+			continue
+		}
+		res = append(res, a)
+	}
+	return res
+}
+
+// Value converts the document to maps, arrays and strings, so it can be compared like a decoded JSON:
+// the element without attributes and child elements is a string,
+// other elements are maps of attributes (AttrPrefix + name), text (TextKey) and child elements,
+// repeated child elements are arrays
+func (n *Node) Value() interface{} {
+	return map[string]interface{}{n.Name: n.value()}
+}
+
+func (n *Node) value() interface{} {
+	text := strings.TrimSpace(n.Text)
+	if len(n.Attrs) == 0 && len(n.Children) == 0 {
+		return text
+	}
+
+	res := make(map[string]interface{}, len(n.Attrs)+len(n.Children)+1)
+	for _, a := range n.Attrs {
+		res[AttrPrefix+a.Name.Local] = a.Value
+	}
+	if text != "" {
+		res[TextKey] = text
+	}
+	for _, child := range n.Children {
+		value := child.value()
+		switch existing := res[child.Name].(type) {
+		case nil:
+			res[child.Name] = value
+		case []interface{}:
+			res[child.Name] = append(existing, value)
+		default:
+			res[child.Name] = []interface{}{existing, value}
+		}
+	}
+	return res
+}
+
+// String returns text of the element and all its descendants
+func (n *Node) String() string {
+	var sb strings.Builder
+	n.writeText(&sb)
+	return strings.TrimSpace(sb.String())
+}
+
+func (n *Node) writeText(sb *strings.Builder) {
+	sb.WriteString(n.Text)
+	for _, child := range n.Children {
+		child.writeText(sb)
+	}
+}
+
+// Attr returns the value of the attribute of the element
+func (n *Node) Attr(name string) (string, bool) {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,313 @@
+package xmldoc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Query returns the string value of the first item selected by the XPath expression.
+// The subset of XPath is supported:
+//   - absolute and relative location paths with / and //, relative paths start from the document;
+//   - steps: element name, *, ., .., @attribute, @*, text();
+//   - predicates: [n], [last()], [@attribute], [@attribute='value'], [name='value'], [text()='value'].
+//
+// Namespace prefixes of names are ignored.
+func (n *Node) Query(expr string) (string, bool, error) {
+	steps, err := parsePath(expr)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid XPath %s: %s", expr, err)
+	}
+
+	document := &Node{Children: []*Node{n}}
+	items := []item{{node: document}}
+	for _, s := range steps {
+		items = s.apply(items)
+	}
+	if len(items) == 0 {
+		return "", false, nil
+	}
+	return items[0].String(), true, nil
+}
+
+// item is the element, the attribute or the text selected by the step
+type item struct {
+	node  *Node
+	value string // value of the attribute or the text
+	leaf  bool   // the item is the attribute or the text
+}
+
+func (i item) String() string {
+	if i.leaf {
+		return strings.TrimSpace(i.value)
+	}
+	return i.node.String()
+}
+
+type step struct {
+	descendants bool // the step is preceded by //
+	name        string
+	predicates  []predicate
+}
+
+type predicate struct {
+	position int    // 1-based position, -1 is last()
+	attr     string // name of the attribute
+	child    string // name of the child element or text() for the text of the element
+	value    *string
+}
+
+func (s step) apply(items []item) []item {
+	var res []item
+	seen := make(map[*Node]bool)
+	for _, it := range items {
+		if it.leaf {
+			continue
+		}
+		contexts := []*Node{it.node}
+		if s.descendants {
+			contexts = descendantsOrSelf(it.node)
+		}
+		for _, ctx := range contexts {
+			for _, selected := range s.selectFrom(ctx) {
+				if !selected.leaf {
+					if seen[selected.node] {
+						continue
+					}
+					seen[selected.node] = true
+				}
+				res = append(res, selected)
+			}
+		}
+	}
+	return res
+}
+
+// selectFrom returns items selected by the step and filtered by its predicates in the context of the node
+func (s step) selectFrom(ctx *Node) []item {
+	var selected []item
+	switch {
+	case s.name == ".":
+		selected = []item{{node: ctx}}
+	case s.name == "..":
+		if ctx.Parent != nil {
+			selected = []item{{node: ctx.Parent}}
+		}
+	case s.name == "text()":
+		if ctx.Text != "" {
+			selected = []item{{node: ctx, value: ctx.Text, leaf: true}}
+		}
+	case strings.HasPrefix(s.name, "@"):
+		name := s.name[1:]
+		for _, a := range ctx.Attrs {
+			if name == "*" || localName(name) == a.Name.Local {
+				selected = append(selected, item{node: ctx, value: a.Value, leaf: true})
+			}
+		}
+	default:
+		for _, child := range ctx.Children {
+			if s.name == "*" || localName(s.name) == child.Name {
+				selected = append(selected, item{node: child})
+			}
+		}
+	}
+
+	for _, p := range s.predicates {
+		selected = p.filter(selected)
+	}
+	return selected
+}
+
+func (p predicate) filter(items []item) []item {
+	if p.position != 0 {
+		i := p.position - 1
+		if p.position < 0 {
+			i = len(items) - 1
+		}
+		if i < 0 || i >= len(items) {
+			return nil
+		}
+		return items[i : i+1]
+	}
+
+	var res []item
+	for _, it := range items {
+		if !it.leaf && p.matches(it.node) {
+			res = append(res, it)
+		}
+	}
+	return res
+}
+
+func (p predicate) matches(n *Node) bool {
+	if p.attr != "" {
+		value, ok := n.Attr(localName(p.attr))
+		return ok && (p.value == nil || value == *p.value)
+	}
+	if p.child == "text()" {
+		return p.value == nil || strings.TrimSpace(n.Text) == *p.value
+	}
+	for _, child := range n.Children {
+		if child.Name == localName(p.child) && (p.value == nil || child.String() == *p.value) {
+			return true
+		}
+	}
+	return false
+}
+
+func descendantsOrSelf(n *Node) []*Node {
+	res := []*Node{n}
+	for _, child := range n.Children {
+		res = append(res, descendantsOrSelf(child)...)
+	}
+	return res
+}
+
+func localName(name string) string {
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+func parsePath(expr string) ([]step, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, fmt.Errorf("path is empty")
+	}
+
+	var steps []step
+	descendants := false
+	rest := strings.TrimPrefix(expr, "/")
+	if strings.HasPrefix(rest, "/") {
+		descendants = true
+		rest = rest[1:]
+	}
+	for {
+		end := stepEnd(rest)
+		s, err := parseStep(rest[:end])
+		if err != nil {
+			return nil, err
+		}
+		s.descendants = descendants
+		steps = append(steps, s)
+
+		if end == len(rest) {
+			break
+		}
+		rest = rest[end+1:]
+		descendants = strings.HasPrefix(rest, "/")
+		if descendants {
+			rest = rest[1:]
+		}
+	}
+	return steps, nil
+}
+
+// stepEnd returns the position of the slash which ends the step, slashes in predicates are skipped
+func stepEnd(expr string) int {
+	depth := 0
+	var quote rune
+	for i, r := range expr {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '[':
+			depth++
+		case r == ']':
+			depth--
+		case r == '/' && depth == 0:
+			return i
+		}
+	}
+	return len(expr)
+}
+
+func parseStep(expr string) (step, error) {
+	name := expr
+	var predicates []string
+	if i := strings.Index(expr, "["); i >= 0 {
+		name = expr[:i]
+		rest := expr[i:]
+		for rest != "" {
+			if rest[0] != '[' {
+				return step{}, fmt.Errorf("unexpected %s", rest)
+			}
+			end := predicateEnd(rest)
+			if end < 0 {
+				return step{}, fmt.Errorf("unclosed predicate in %s", expr)
+			}
+			predicates = append(predicates, rest[1:end])
+			rest = rest[end+1:]
+		}
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return step{}, fmt.Errorf("empty step")
+	}
+	s := step{name: name}
+	for _, p := range predicates {
+		parsed, err := parsePredicate(strings.TrimSpace(p))
+		if err != nil {
+			return step{}, err
+		}
+		s.predicates = append(s.predicates, parsed)
+	}
+	return s, nil
+}
+
+func predicateEnd(expr string) int {
+	var quote rune
+	for i, r := range expr {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+func parsePredicate(expr string) (predicate, error) {
+	if expr == "last()" {
+		return predicate{position: -1}, nil
+	}
+	if position, err := strconv.Atoi(expr); err == nil {
+		if position < 1 {
+			return predicate{}, fmt.Errorf("position must be positive, got %d", position)
+		}
+		return predicate{position: position}, nil
+	}
+
+	var p predicate
+	operand := expr
+	if i := strings.Index(expr, "="); i >= 0 {
+		operand = strings.TrimSpace(expr[:i])
+		literal := strings.TrimSpace(expr[i+1:])
+		if len(literal) < 2 || (literal[0] != '\'' && literal[0] != '"') || literal[len(literal)-1] != literal[0] {
+			return predicate{}, fmt.Errorf("invalid string literal %s", literal)
+		}
+		value := literal[1 : len(literal)-1]
+		p.value = &value
+	}
+
+	switch {
+	case strings.HasPrefix(operand, "@") && len(operand) > 1:
+		p.attr = operand[1:]
+	case operand != "" && !strings.ContainsAny(operand, "[]/()") || operand == "text()":
+		p.child = operand
+	default:
+		return predicate{}, fmt.Errorf("unsupported predicate [%s]", expr)
+	}
+	return p, nil
+}